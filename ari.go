@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// acmeDirectories are the ACME directories of the CAs known to offer
+// renewal information, by the organization of the certificates' issuer.
+// The acme_directories setting adds to them.
+var acmeDirectories = map[string]string{
+	"Let's Encrypt":         "https://acme-v02.api.letsencrypt.org/directory",
+	"Google Trust Services": "https://dv.acme-v02.api.pki.goog/directory",
+}
+
+// checksARI reports whether the group asks ACME CAs when to renew its
+// certificates: its own ari setting if it has one, else the global one.
+func (group *WatchGroup) checksARI() bool {
+	if group.ARI != nil {
+		return *group.ARI
+	}
+	return group.config.ARI
+}
+
+// checkRenewalInfo asks the CA of the leaf of chain for its suggested
+// renewal window (ACME Renewal Information, RFC 9773) and marks r when the
+// window has started, as it is when auto-renewal is broken or the CA is
+// about to revoke the certificate. Like an OCSP failure it is only reported
+// for sites that are fine otherwise. Certificates of other CAs and failed
+// queries are left alone, the latter with a log line.
+func (group *WatchGroup) checkRenewalInfo(ctx context.Context, r *Result, chain []*x509.Certificate, timeout time.Duration) {
+	leaf := chain[0]
+	directory := group.config.acmeDirectory(leaf)
+	if directory == "" || r.Status != StatusOK {
+		return
+	}
+	info, err := group.config.renewalInfo(ctx, group.httpClient(timeout), directory, leaf)
+	if err != nil {
+		slog.Warn("failed to get the renewal info", "site", r.Site, "directory", directory, "error", err)
+		return
+	}
+	start := info.SuggestedWindow.Start
+	if start.IsZero() || time.Now().Before(start) {
+		return
+	}
+	r.Status, r.Reason = StatusRenewalDue, "renewal window opened "+start.In(group.loc).Format("2006-01-02 15:04")
+	if info.ExplanationURL != "" {
+		r.Reason += ", see " + info.ExplanationURL
+	}
+	slog.Warn("certificate is due for renewal", "site", r.Site, "window_start", start, "window_end", info.SuggestedWindow.End)
+}
+
+// acmeDirectory returns the ACME directory of the CA that issued cert, or
+// "" when it is not known to be an ACME CA.
+func (config *Config) acmeDirectory(cert *x509.Certificate) string {
+	for _, org := range cert.Issuer.Organization {
+		if dir := config.ACMEDirectories[org]; dir != "" {
+			return dir
+		}
+		if dir := acmeDirectories[org]; dir != "" {
+			return dir
+		}
+	}
+	return ""
+}
+
+type renewalInfo struct {
+	SuggestedWindow struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"suggestedWindow"`
+	ExplanationURL string `json:"explanationURL"`
+}
+
+// renewalInfo fetches the renewal information of cert from the CA with
+// directory. The directory's renewalInfo URL is looked up once per config.
+func (config *Config) renewalInfo(ctx context.Context, client *http.Client, directory string, cert *x509.Certificate) (*renewalInfo, error) {
+	id, err := ariCertID(cert)
+	if err != nil {
+		return nil, err
+	}
+	base, ok := config.renewalInfoURLs.Load(directory)
+	if !ok {
+		body, err := fetchURL(ctx, client, directory, nil)
+		if err != nil {
+			return nil, fmt.Errorf("directory: %w", err)
+		}
+		var dir struct {
+			RenewalInfo string `json:"renewalInfo"`
+		}
+		if err := json.Unmarshal(body, &dir); err != nil {
+			return nil, fmt.Errorf("directory: %w", err)
+		}
+		if dir.RenewalInfo == "" {
+			return nil, errors.New("the CA offers no renewal info")
+		}
+		base, _ = config.renewalInfoURLs.LoadOrStore(directory, dir.RenewalInfo)
+	}
+	body, err := fetchURL(ctx, client, base.(string)+"/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	var info renewalInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ariCertID is the certificate's ID for renewal info requests: its
+// authority key identifier and the DER content of its serial number, both
+// base64url-encoded.
+func ariCertID(cert *x509.Certificate) (string, error) {
+	if len(cert.AuthorityKeyId) == 0 {
+		return "", errors.New("no authority key identifier")
+	}
+	serial := cert.SerialNumber.Bytes()
+	if len(serial) == 0 || serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...) // positive as a DER INTEGER
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(cert.AuthorityKeyId) + "." + enc.EncodeToString(serial), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("bark", func(decode func(any) error) (Notifier, error) {
+		n := &BarkNotifier{Server: "https://api.day.app", Group: "crtwtch"}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.DeviceKey == "" {
+			return nil, fmt.Errorf("bark: device_key is required")
+		}
+		n.Server = strings.TrimRight(n.Server, "/")
+		return n, nil
+	})
+}
+
+// BarkNotifier pushes to the Bark iOS app. Group, Icon and Sound let
+// certificate alerts stand apart from other pushes on the device.
+type BarkNotifier struct {
+	Server    string `toml:"server"`
+	DeviceKey string `toml:"device_key"`
+	Group     string `toml:"group"`
+	Icon      string `toml:"icon"`
+	Sound     string `toml:"sound"`
+}
+
+type barkMessage struct {
+	DeviceKey string `json:"device_key"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	Group     string `json:"group,omitempty"`
+	Icon      string `json:"icon,omitempty"`
+	Sound     string `json:"sound,omitempty"`
+	Level     string `json:"level"`
+}
+
+// barkLevel maps levels onto iOS interruption levels.
+func barkLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "timeSensitive"
+	case level >= slog.LevelWarn:
+		return "active"
+	}
+	return "passive"
+}
+
+func (n *BarkNotifier) Send(msg string, level slog.Level) error {
+	title, body := splitMessage(msg)
+	if _, err := postJSON(n.Server+"/push", barkMessage{
+		DeviceKey: n.DeviceKey,
+		Title:     title,
+		Body:      body,
+		Group:     n.Group,
+		Icon:      n.Icon,
+		Sound:     n.Sound,
+		Level:     barkLevel(level),
+	}); err != nil {
+		return fmt.Errorf("bark: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,769 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Status classifies the outcome of checking one site.
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarning
+	StatusCritical // at most the critical threshold of days left
+	StatusExpired
+	StatusFailed
+	StatusSkipped          // not checked before the run's deadline
+	StatusUntrusted        // the chain does not verify, see Reason
+	StatusMismatch         // the certificate does not cover the host name, see Reason
+	StatusRevoked          // the OCSP responder says the certificate is revoked
+	StatusOCSPFailed       // the OCSP responder could not tell, see Reason
+	StatusStaple           // Must-Staple certificate without a valid staple, see Reason
+	StatusCT               // publicly trusted but without enough valid SCTs, see Reason
+	StatusUnexpectedIssuer // found in the CT logs, issued by a CA off the list
+	StatusPinMismatch      // no certificate of the chain matches the site's pins
+	StatusHygiene          // weak keys or signatures in the chain, see Reason
+	StatusTLSPolicy        // an old protocol version or a cipher suite off the list
+	StatusSelfSigned       // an untrusted self-signed certificate, see Reason
+	StatusValidity         // not valid yet or valid for too long, see Reason
+	StatusDANE             // no TLSA record matches the chain, see Reason
+	StatusRenewalDue       // the CA's suggested renewal window has started, see Reason
+	StatusDrift            // the leaf differs from the site's inventory, see Reason
+	StatusDuplicate        // the leaf's key or serial is also served by other hosts
+	StatusSlow             // the TLS handshake took longer than slow_handshake
+
+	numStatus = iota
+)
+
+var statusNames = [numStatus]string{"ok", "warning", "critical", "expired", "failed", "skipped", "untrusted", "mismatch", "revoked", "ocsp_failed", "staple", "ct", "unexpected_issuer", "pin_mismatch", "hygiene", "tls_policy", "self_signed", "validity", "dane", "renewal_due", "drift", "duplicate", "slow"}
+
+func (s Status) String() string {
+	if s >= 0 && int(s) < len(statusNames) {
+		return statusNames[s]
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// expiring reports whether the status is a certificate running out, not
+// yet expired.
+func (s Status) expiring() bool {
+	return s == StatusWarning || s == StatusCritical
+}
+
+// Level maps the status to the severity used for notifications.
+func (s Status) Level() slog.Level {
+	switch s {
+	case StatusWarning, StatusSkipped, StatusOCSPFailed, StatusHygiene, StatusRenewalDue, StatusDuplicate, StatusSlow:
+		return slog.LevelWarn
+	case StatusCritical, StatusExpired, StatusFailed, StatusUntrusted, StatusMismatch, StatusRevoked, StatusStaple, StatusCT, StatusUnexpectedIssuer, StatusPinMismatch, StatusTLSPolicy, StatusSelfSigned, StatusValidity, StatusDANE, StatusDrift:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// Result is the outcome of checking one site.
+type Result struct {
+	Site     string
+	Expiry   time.Time
+	DaysLeft int           // whole days of Left, rounded down
+	Left     time.Duration // until Expiry, negative once expired
+	Issuer   string
+	IssuerDN string // full issuer DN of the leaf
+	Serial   string // hex, of the leaf
+	// Intermediate is set when Expiry is that of a CA certificate of the
+	// chain, named by Subject, which expires before the leaf.
+	Intermediate bool
+	Subject      string
+	// TLSVersion and CipherSuite are what the handshake negotiated, empty
+	// for certificates not read from a server.
+	TLSVersion  string
+	CipherSuite string
+	IP          string // the address checked, for sites checked on all their IPs
+	MX          string // the mail host checked, for mx: sites
+	// SANs are the DNS names and IP addresses of the leaf, ChainLength the
+	// number of certificates in its chain.
+	SANs        []string
+	ChainLength int
+	// Connect and Handshake are how long the TCP connect and the TLS
+	// handshake took, zero for certificates not read from a server; QUIC
+	// sites only have Handshake.
+	Connect   time.Duration
+	Handshake time.Duration
+	// Reason tells what is wrong with a certificate that was found, such as
+	// the verification error of an untrusted chain, the names a mismatched
+	// certificate is valid for, when it was revoked or what is wrong with
+	// its OCSP staple, its SCTs, its key when pinned, its weak crypto or
+	// the handshake that violates the TLS policy, or the names and issuer of
+	// a certificate found in the CT logs.
+	Reason   string
+	Status   Status
+	Err      error
+	Attempts int
+	Duration time.Duration // checking the site took, retries included
+	// PrevExpiry is the expiry of the certificate this one renewed, set on
+	// the results of renewal notices only.
+	PrevExpiry time.Time
+	// PrevIssuer is the issuer DN the site had before, set on the results
+	// of issuer change notices only.
+	PrevIssuer string
+
+	issuerOrg string // organization of the leaf's issuer, or its DN
+	keyPin    string // public key pin of the leaf
+	leafHash  string // SHA-256 of the leaf, hex
+	host      string // the host name the site is for, or its address
+	recovered bool   // OK, and the status last sent was not or is unknown
+
+	messages *messageSet
+}
+
+// TimedOut reports whether the check failed because the site did not answer
+// within the timeout, as opposed to refusing or breaking the connection.
+func (r *Result) TimedOut() bool {
+	if r.Err == nil {
+		return false
+	}
+	var ne net.Error
+	return errors.Is(r.Err, context.DeadlineExceeded) || (errors.As(r.Err, &ne) && ne.Timeout())
+}
+
+// hoursWindow is the time left under which messages count it in hours,
+// since "0 days" could mean anything up to a day.
+const hoursWindow = 48 * time.Hour
+
+// InHours reports whether the certificate has less than hoursWindow left and
+// has not expired yet, so messages give HoursLeft rather than DaysLeft.
+func (r *Result) InHours() bool {
+	return r.Left >= 0 && r.Left < hoursWindow && !r.Expiry.IsZero()
+}
+
+// HoursLeft is Left in whole hours, rounded down.
+func (r *Result) HoursLeft() int {
+	return int(r.Left.Hours())
+}
+
+// daysOf is left in whole days, rounded down, so a certificate that expired
+// an hour ago has -1 days left rather than 0.
+func daysOf(left time.Duration) int {
+	return int(math.Floor(left.Hours() / 24))
+}
+
+// Message renders the alert line for the result.
+func (r *Result) Message() string {
+	return r.messages.resultLine(r)
+}
+
+// Report is the result of one pass over a group.
+type Report struct {
+	Group   string
+	Date    time.Time
+	Results []Result
+
+	messages *messageSet
+	listAll  bool // Shown is every result, not only the alerts
+}
+
+// Alerts returns the results that need attention, in check order.
+func (rep *Report) Alerts() []Result {
+	alerts := make([]Result, 0)
+	for _, r := range rep.Results {
+		if r.Status != StatusOK {
+			alerts = append(alerts, r)
+		}
+	}
+	return alerts
+}
+
+// Shown returns the results a notification lists: the alerts, or every
+// result for reports of changes and digests, which also list the sites
+// that are OK.
+func (rep *Report) Shown() []Result {
+	if rep.listAll {
+		return rep.Results
+	}
+	return rep.Alerts()
+}
+
+// Level is the highest severity found in the report.
+func (rep *Report) Level() slog.Level {
+	level := slog.LevelInfo
+	for _, r := range rep.Results {
+		level = max(level, r.Status.Level())
+	}
+	return level
+}
+
+// Title renders the first line of the notification.
+func (rep *Report) Title() string {
+	return rep.messages.reportTitle(rep)
+}
+
+// Text renders the report as a plain text message.
+func (rep *Report) Text() string {
+	lines := []string{rep.Title()}
+	for _, r := range rep.Shown() {
+		lines = append(lines, r.Message())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Run checks every site of the group once and pushes the result. The error
+// reports notification channels that failed to deliver.
+func (group *WatchGroup) Run(ctx context.Context) error {
+	slog.Info("watching group:", "name", group.Name)
+	ctx, span := tracer.Start(ctx, "pass "+group.Name)
+	defer span.End()
+	today := time.Now().In(group.loc)
+	rep := &Report{Group: group.Name, Date: today, messages: group.messages}
+	rep.Results = group.checkAll(ctx, func(site *Site) []Result {
+		return group.traceCheck(ctx, site, func(ctx context.Context) []Result {
+			if _, ok := mxDomain(site.Addr); ok {
+				return group.checkMX(ctx, site, today)
+			}
+			if group.checksAllIPs(site) {
+				return group.checkIPs(ctx, site, today)
+			}
+			return []Result{group.check(ctx, site, today)}
+		})
+	})
+	if group.Kubernetes != nil {
+		rep.Results = append(rep.Results, group.checkSecrets(ctx, today)...)
+	}
+	if group.CTMonitor != nil {
+		rep.Results = append(rep.Results, group.checkCTLogs(ctx, today)...)
+	}
+	renewed, reissued := group.observe(rep)
+	group.checkDuplicates(rep)
+	group.config.history.record(group.Name, rep)
+	metrics.record(group.Name, rep)
+	group.config.Pushgateway.push(group.Name)
+	group.config.InfluxDB.write(group.Name, rep)
+	group.runHooks(rep, renewed)
+	err := errors.Join(group.weekly(rep), group.report(rep), group.notifyRenewed(rep, renewed), group.notifyIssuerChanged(reissued))
+	return group.beat(rep, err)
+}
+
+// report sends the result of a pass, as far as the group's digest, mute,
+// cooldown and quiet hour settings let it through.
+func (group *WatchGroup) report(rep *Report) error {
+	if group.digestAt.enabled {
+		return group.digest(rep)
+	}
+	rep, send := group.withoutMuted(rep)
+	if send {
+		rep, send = group.filterReport(rep)
+	}
+	if !send {
+		slog.Info("nothing new to send", "group", group.Name)
+		group.recordState(rep)
+		return nil
+	}
+	if group.holdQuiet(rep) {
+		return nil
+	}
+	if alerts := rep.Alerts(); len(alerts) <= 0 {
+		slog.Info("no alerts to send")
+	} else {
+		slog.Info("sending alerts", "count", len(alerts))
+	}
+	if err := group.notify(rep); err != nil {
+		// alert again next time rather than risk the alert being lost
+		return err
+	}
+	group.recordState(rep)
+	return nil
+}
+
+func (group *WatchGroup) check(ctx context.Context, site *Site, today time.Time) Result {
+	slog.Info("checking site:", "site", site)
+	r := Result{Site: site.Addr, messages: group.messages}
+	peer, err := group.fetchWithRetry(ctx, site, &r)
+	if err != nil {
+		r.Status, r.Err = StatusFailed, err
+		slog.Error("failed to check cert:", "site", site, "attempts", r.Attempts, "timed_out", r.TimedOut(), "error", err)
+		return r
+	}
+	chain := peer.chain
+	r.Connect, r.Handshake = peer.connect, peer.handshake
+	if peer.version != 0 {
+		r.TLSVersion, r.CipherSuite = tls.VersionName(peer.version), tls.CipherSuiteName(peer.cipher)
+	}
+	group.grade(&r, site, chain, today)
+	if r.Status == StatusExpired {
+		// whatever else is wrong with it, it needs replacing anyway
+		return r
+	}
+	if site.dialed() {
+		group.checkCT(&r, peer)
+		group.checkTLSPolicy(&r, peer)
+	}
+	// a certificate for another name breaks the site however long it is
+	// valid
+	if name := site.serverName(); name != "" {
+		if err := chain[0].VerifyHostname(name); err != nil {
+			r.Status, r.Reason = StatusMismatch, err.Error()
+			slog.Warn("certificate does not match the host name", "site", r.Site, "error", err)
+		}
+	}
+	var tlsaName string
+	var tlsa []tlsaRecord
+	if site.dialed() && group.checksDANE() {
+		tlsaName, tlsa = group.tlsaRecords(ctx, site)
+	}
+	if site.dialed() {
+		group.checkSelfSigned(&r, site, chain, tlsa)
+	}
+	checkPins(&r, site, chain)
+	checkDrift(&r, site, chain)
+	group.checkDANE(&r, tlsaName, tlsa, chain)
+	var stapled *ocsp.Response
+	if site.dialed() {
+		stapled = group.checkStaple(&r, chain, peer.staple)
+	}
+	// a valid staple already tells what the responder would
+	if group.checksOCSP() && stapled == nil {
+		group.checkRevocation(ctx, &r, chain, group.timeout(site))
+	}
+	if group.checksARI() {
+		group.checkRenewalInfo(ctx, &r, chain, group.timeout(site))
+	}
+	group.checkLatency(&r, site)
+	return r
+}
+
+// checkLatency marks r when the TLS handshake took longer than the site's
+// slow_handshake, as it does when the server fetches its OCSP staple inline
+// or sends a bloated chain. Like an OCSP failure it is only reported for
+// sites that are fine otherwise.
+func (group *WatchGroup) checkLatency(r *Result, site *Site) {
+	limit := group.slowHandshake(site)
+	if limit == 0 || r.Handshake <= limit || r.Status != StatusOK {
+		return
+	}
+	r.Status, r.Reason = StatusSlow, fmt.Sprintf("handshake took %s, over %s", r.Handshake.Round(time.Millisecond), limit)
+	slog.Warn("handshake is slow", "site", r.Site, "handshake", r.Handshake, "limit", limit)
+}
+
+// grade fills in r from the certificate chain found for it, leaf first.
+// Every certificate of the chain is held to its redline, the leaf's or the
+// CA one, since an intermediate that expires breaks the site just the same;
+// r reports the worst of them, and of equally bad ones the first to expire.
+func (group *WatchGroup) grade(r *Result, site *Site, chain []*x509.Certificate, today time.Time) {
+	left := func(cert *x509.Certificate) time.Duration {
+		return cert.NotAfter.Sub(today)
+	}
+	leaf := chain[0]
+	critical := group.critical(site)
+	first, status := leaf, expiryStatus(left(leaf), group.redline(site), critical)
+	for _, cert := range chain[1:] {
+		s := expiryStatus(left(cert), group.caRedline(site), critical)
+		if s > status || s == status && cert.NotAfter.Before(first.NotAfter) {
+			first, status = cert, s
+		}
+	}
+	if first != leaf {
+		r.Intermediate, r.Subject = true, subjectName(first)
+	}
+	r.Expiry = first.NotAfter.In(group.loc)
+	r.Issuer = issuerName(leaf)
+	r.IssuerDN = leaf.Issuer.String()
+	r.issuerOrg = strings.Join(leaf.Issuer.Organization, ", ")
+	if r.issuerOrg == "" {
+		r.issuerOrg = r.IssuerDN
+	}
+	r.Serial = leaf.SerialNumber.Text(16)
+	r.SANs, r.ChainLength = certNames(leaf), len(chain)
+	r.keyPin = spkiPin(leaf)
+	sum := sha256.Sum256(leaf.Raw)
+	r.leafHash = hex.EncodeToString(sum[:])
+	if r.host = site.serverName(); r.host == "" {
+		r.host = site.Addr
+	}
+	r.Left = left(first)
+	r.DaysLeft = daysOf(r.Left)
+	r.Status = status
+	slog.Info("site checked:", "site", r.Site, "expire", r.Expiry.Format("2006-01-02"), "days_left", r.DaysLeft, "intermediate", r.Intermediate, "connect", r.Connect, "handshake", r.Handshake)
+	if group.checksHygiene() {
+		group.checkHygiene(r, chain)
+	}
+	// an expired chain does not verify either, and expired says more
+	if group.verifies() && r.Status != StatusExpired {
+		if err := verifyChain(chain, group.roots); err != nil {
+			r.Status, r.Reason = StatusUntrusted, err.Error()
+			slog.Warn("certificate chain does not verify", "site", r.Site, "error", err)
+		}
+	}
+	checkValidity(r, chain, today)
+}
+
+// maxValidity is the longest validity in days the CA/Browser Forum allows
+// publicly trusted leaf certificates.
+const maxValidity = 398
+
+// checkValidity marks r when the leaf is not valid yet, which clients refuse
+// like an expired one and which says more than a chain that does not verify,
+// or when it is valid for longer than maxValidity days. The former only
+// replaces OK, Warning, Critical or Untrusted, so an expired CA certificate
+// of the chain still shows, and the latter only OK or Warning. The limit
+// is only checked for chains to the public roots, certificates of an
+// internal CA are usually valid for years.
+func checkValidity(r *Result, chain []*x509.Certificate, now time.Time) {
+	leaf := chain[0]
+	if leaf.NotBefore.After(now) {
+		if r.Status == StatusOK || r.Status.expiring() || r.Status == StatusUntrusted {
+			r.Status, r.Reason = StatusValidity, "not valid before "+leaf.NotBefore.In(now.Location()).Format("2006-01-02 15:04")
+		}
+		slog.Warn("certificate not valid yet", "site", r.Site, "not_before", leaf.NotBefore)
+		return
+	}
+	days := int(leaf.NotAfter.Sub(leaf.NotBefore).Hours() / 24)
+	if days > maxValidity && (r.Status == StatusOK || r.Status == StatusWarning) && verifyChain(chain, nil) == nil {
+		r.Status, r.Reason = StatusValidity, fmt.Sprintf("valid for %d days, over the %d allowed", days, maxValidity)
+		slog.Warn("certificate valid for too long", "site", r.Site, "days", days)
+	}
+}
+
+// verifies reports whether the group verifies chains against trust roots.
+func (group *WatchGroup) verifies() bool {
+	return group.Verify || group.config.Verify || group.roots != nil
+}
+
+// verifyChain verifies the leaf of chain against roots, or the system roots
+// when nil, with the rest of the chain as intermediates. The host name is
+// not checked.
+func verifyChain(chain []*x509.Certificate, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+// checkSelfSigned marks r when the server presents nothing but an untrusted
+// self-signed certificate, which is usually a default certificate served by
+// mistake. Sites that pin their certificate or publish it in TLSA records,
+// and groups that trust it in their ca_bundle, mean to serve it.
+func (group *WatchGroup) checkSelfSigned(r *Result, site *Site, chain []*x509.Certificate, tlsa []tlsaRecord) {
+	leaf := chain[0]
+	if len(chain) != 1 || len(site.Pins) > 0 || !bytes.Equal(leaf.RawIssuer, leaf.RawSubject) || verifyChain(chain, group.roots) == nil {
+		return
+	}
+	if group.daneMatches(tlsa, chain) {
+		return
+	}
+	r.Status, r.Reason = StatusSelfSigned, "self-signed certificate of "+subjectName(leaf)
+	slog.Warn("self-signed certificate", "site", r.Site, "subject", subjectName(leaf))
+}
+
+// loadCABundle returns the system roots together with the certificates of
+// the PEM file at path, so a group can watch internal and public sites
+// alike. An empty path returns nil.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		slog.Warn("failed to load the system roots, trusting only the CA bundle", "path", path, "error", err)
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return pool, nil
+}
+
+// expiryStatus grades a certificate with left until it expires, critical
+// at 0 for none. It expires to the second, not at the end of its last day.
+func expiryStatus(left time.Duration, redline, critical int) Status {
+	switch {
+	case left < 0:
+		return StatusExpired
+	case critical > 0 && daysOf(left) <= critical:
+		return StatusCritical
+	case daysOf(left) <= redline:
+		return StatusWarning
+	}
+	return StatusOK
+}
+
+// caRedline is the days left at which CA certificates of the site's chain
+// start to warn: the site's ca_redline, else the group's, by default the
+// same as the leaf's.
+func (group *WatchGroup) caRedline(site *Site) int {
+	for _, days := range []int{site.CARedline, group.CARedline} {
+		if days > 0 {
+			return days
+		}
+	}
+	return group.redline(site)
+}
+
+// redline is the days left at which the site's certificates start to warn:
+// the highest reminder mark of the site or its redline, else the same of
+// the group.
+func (group *WatchGroup) redline(site *Site) int {
+	if n := len(site.RemindAt); n > 0 {
+		return site.RemindAt[n-1]
+	}
+	if site.Redline > 0 {
+		return site.Redline
+	}
+	if n := len(group.RemindAt); n > 0 {
+		return group.RemindAt[n-1]
+	}
+	return group.DayBeforeExpiration
+}
+
+// critical is the days left at which the site's certificates turn from
+// warning to critical: the site's critical, else the group's, 0 for never.
+func (group *WatchGroup) critical(site *Site) int {
+	if site.Critical > 0 {
+		return site.Critical
+	}
+	return group.Critical
+}
+
+// reminderMark is the lowest reminder mark of the site daysLeft has
+// reached, or 0 when it has no marks. Sites with a redline of their own
+// leave the group's marks.
+func (group *WatchGroup) reminderMark(site *Site, daysLeft int) int {
+	marks := site.RemindAt
+	if len(marks) == 0 && site.Redline == 0 {
+		marks = group.RemindAt
+	}
+	for _, mark := range marks { // sorted ascending
+		if daysLeft <= mark {
+			return mark
+		}
+	}
+	return 0
+}
+
+// errSkipped is the error of sites the deadline did not leave time for.
+var errSkipped = errors.New("deadline reached before the check started")
+
+func (group *WatchGroup) skipped(site *Site) Result {
+	slog.Warn("site skipped, deadline reached", "site", site)
+	return Result{Site: site.Addr, Status: StatusSkipped, Err: errSkipped, messages: group.messages}
+}
+
+// fetchWithRetry fetches what the site presents, retrying failures as
+// configured for the site. The number of attempts made is recorded in r.
+func (group *WatchGroup) fetchWithRetry(ctx context.Context, site *Site, r *Result) (*peerCerts, error) {
+	retry := group.checkRetry(site)
+	delay := time.Duration(retry.RetryDelay) * time.Second
+	for {
+		r.Attempts++
+		peer, err := fetchChain(ctx, site, group.timeout(site), group.dialer(site), group.clientCert(site))
+		if err == nil || r.Attempts > retry.Retries || ctx.Err() != nil {
+			return peer, err
+		}
+		slog.Warn("check failed, retrying", "site", site, "attempt", r.Attempts, "wait", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * retry.RetryBackoff)
+	}
+}
+
+// hostPort returns site with the default HTTPS port added when it has none.
+func hostPort(site string) string {
+	return withPort(site, "443")
+}
+
+// checkError tells which phase of a check failed.
+type checkError struct {
+	Phase string
+	Err   error
+}
+
+func (e *checkError) Error() string { return e.Phase + ": " + e.Err.Error() }
+func (e *checkError) Unwrap() error { return e.Err }
+
+// peerCerts is what a site presents: its certificate chain, and for servers
+// what they send along with it in the handshake.
+type peerCerts struct {
+	chain  []*x509.Certificate // leaf first
+	staple []byte              // the stapled OCSP response
+	scts   [][]byte            // signed certificate timestamps
+	// the negotiated protocol version and cipher suite
+	version, cipher uint16
+	// how long the TCP connect and the TLS handshake took
+	connect, handshake time.Duration
+}
+
+// fetchChain returns the certificate chain presented by site with the rest
+// of its handshake, or the chain read from it for file sites. The TCP dial,
+// the protocol's upgrade to TLS if it has one, and the TLS handshake each
+// get their own timeout; protocols with a transport of their own get one
+// for the whole handshake. A server asking for a client certificate gets
+// client, or none when it is nil.
+func fetchChain(ctx context.Context, site *Site, timeout time.Duration, d dialer, client *tls.Certificate) (*peerCerts, error) {
+	proto, addr, err := siteTarget(site.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if proto.read != nil {
+		chain, err := proto.read(addr, site.Password)
+		if err != nil {
+			return nil, &checkError{Phase: "read", Err: err}
+		}
+		return &peerCerts{chain: chain}, nil
+	}
+	name, _, _ := net.SplitHostPort(addr)
+	if site.ServerName != "" {
+		name = site.ServerName
+	}
+	config := &tls.Config{
+		ServerName:         name,
+		NextProtos:         proto.alpn,
+		InsecureSkipVerify: true,
+		// accept what the server offers, checkTLSPolicy judges it
+		MinVersion:   tls.VersionTLS10,
+		CipherSuites: allCipherSuites,
+		// sent whichever CAs the server names, it may list none or the wrong ones
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if client == nil {
+				return &tls.Certificate{}, nil
+			}
+			return client, nil
+		},
+	}
+	if proto.handshake != nil {
+		if d.proxy != nil || d.jump != nil {
+			return nil, &checkError{Phase: "dial", Err: errors.New("cannot go through a proxy or jump host")}
+		}
+		hsCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		start := time.Now()
+		state, err := proto.handshake(hsCtx, addr, config, d.networks)
+		if err != nil {
+			return nil, &checkError{Phase: "handshake", Err: err}
+		}
+		peer, err := peerOf(state)
+		if peer != nil {
+			peer.handshake = time.Since(start)
+		}
+		return peer, err
+	}
+	start := time.Now()
+	raw, err := d.dial(ctx, addr, timeout)
+	if err != nil {
+		return nil, &checkError{Phase: "dial", Err: err}
+	}
+	defer raw.Close()
+	connect := time.Since(start)
+
+	if proto.upgrade != nil {
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		raw.SetDeadline(deadline)
+		if err := proto.upgrade(raw, name); err != nil {
+			return nil, &checkError{Phase: "upgrade", Err: err}
+		}
+		raw.SetDeadline(time.Time{})
+	}
+	conn := tls.Client(raw, config)
+	hsCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start = time.Now()
+	if err := conn.HandshakeContext(hsCtx); err != nil {
+		return nil, &checkError{Phase: "handshake", Err: err}
+	}
+	handshake := time.Since(start)
+	peer, err := peerOf(conn.ConnectionState())
+	if peer != nil {
+		peer.connect, peer.handshake = connect, handshake
+	}
+	return peer, err
+}
+
+func peerOf(state tls.ConnectionState) (*peerCerts, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return &peerCerts{
+		chain:   state.PeerCertificates,
+		staple:  state.OCSPResponse,
+		scts:    state.SignedCertificateTimestamps,
+		version: state.Version,
+		cipher:  state.CipherSuite,
+	}, nil
+}
+
+// dialer is how sites are reached: over which networks, or through which
+// proxy or jump host.
+type dialer struct {
+	networks []string
+	proxy    *url.URL
+	jump     *SSHJump
+}
+
+func (group *WatchGroup) dialer(site *Site) dialer {
+	return dialer{networks: group.networks(site), proxy: group.proxyURL(), jump: group.sshJump()}
+}
+
+// dial connects to addr through the jump host or proxy if there is one, else
+// over the first of the networks that works, giving each attempt timeout.
+func (d dialer) dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	if d.jump != nil {
+		return d.jump.dial(ctx, addr, timeout)
+	}
+	if d.proxy != nil {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return dialProxy(dialCtx, d.proxy, addr)
+	}
+	var errs []error
+	for _, network := range d.networks {
+		dialCtx, cancel := context.WithTimeout(ctx, timeout)
+		conn, err := (&net.Dialer{}).DialContext(dialCtx, network, addr)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// issuerName is the issuer CN, or the full DN when the CN is empty.
+func issuerName(cert *x509.Certificate) string {
+	if cert.Issuer.CommonName != "" {
+		return cert.Issuer.CommonName
+	}
+	return cert.Issuer.String()
+}
+
+// subjectName is the subject CN, or the full DN when the CN is empty.
+func subjectName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
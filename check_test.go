@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestDaysOf(t *testing.T) {
+	for _, tt := range []struct {
+		left time.Duration
+		want int
+	}{
+		{-25 * time.Hour, -2},
+		{-24 * time.Hour, -1},
+		{-time.Hour, -1},
+		{-time.Second, -1},
+		{0, 0},
+		{6 * time.Hour, 0},
+		{23*time.Hour + 59*time.Minute, 0},
+		{24 * time.Hour, 1},
+		{47 * time.Hour, 1},
+		{48 * time.Hour, 2},
+		{30 * 24 * time.Hour, 30},
+	} {
+		if got := daysOf(tt.left); got != tt.want {
+			t.Errorf("daysOf(%v) = %d, want %d", tt.left, got, tt.want)
+		}
+	}
+}
+
+func TestExpiryStatus(t *testing.T) {
+	const redline, critical = 7, 2
+	for _, tt := range []struct {
+		left     time.Duration
+		critical int
+		want     Status
+	}{
+		{-time.Hour, critical, StatusExpired},
+		{-time.Second, critical, StatusExpired},
+		{0, critical, StatusCritical},
+		{23*time.Hour + 59*time.Minute, critical, StatusCritical},
+		{2*24*time.Hour + 23*time.Hour, critical, StatusCritical},
+		{3 * 24 * time.Hour, critical, StatusWarning},
+		{7*24*time.Hour + 23*time.Hour, critical, StatusWarning},
+		{8 * 24 * time.Hour, critical, StatusOK},
+		// without a critical threshold the last days are only a warning
+		{0, 0, StatusWarning},
+		{-time.Hour, 0, StatusExpired},
+	} {
+		if got := expiryStatus(tt.left, redline, tt.critical); got != tt.want {
+			t.Errorf("expiryStatus(%v, %d, %d) = %v, want %v", tt.left, redline, tt.critical, got, tt.want)
+		}
+	}
+}
+
+func TestInHours(t *testing.T) {
+	expiry := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		left    time.Duration
+		inHours bool
+		hours   int
+	}{
+		{-time.Hour, false, -1},
+		{0, true, 0},
+		{6 * time.Hour, true, 6},
+		{23*time.Hour + 59*time.Minute, true, 23},
+		{47 * time.Hour, true, 47},
+		{47*time.Hour + 59*time.Minute, true, 47},
+		{48 * time.Hour, false, 48},
+	} {
+		r := Result{Expiry: expiry, Left: tt.left}
+		if got := r.InHours(); got != tt.inHours {
+			t.Errorf("InHours() with %v left = %v, want %v", tt.left, got, tt.inHours)
+		}
+		if got := r.HoursLeft(); got != tt.hours {
+			t.Errorf("HoursLeft() with %v left = %d, want %d", tt.left, got, tt.hours)
+		}
+	}
+	if (&Result{Left: time.Hour}).InHours() {
+		t.Error("InHours() is true for a result without a certificate")
+	}
+}
+
+func TestReportShown(t *testing.T) {
+	rep := &Report{Results: []Result{
+		{Site: "ok.example.com", Status: StatusOK},
+		{Site: "soon.example.com", Status: StatusWarning},
+	}}
+	if got := rep.Shown(); len(got) != 1 || got[0].Site != "soon.example.com" {
+		t.Errorf("Shown() = %v, want the alert only", got)
+	}
+	rep.listAll = true
+	if got := rep.Shown(); len(got) != 2 {
+		t.Errorf("Shown() of a digest = %v, want every result", got)
+	}
+}
+
+func TestCheckValidityNotYetValid(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{NotBefore: now.Add(time.Hour), NotAfter: now.Add(90 * 24 * time.Hour)}
+	for _, tt := range []struct {
+		status, want Status
+	}{
+		{StatusOK, StatusValidity},
+		{StatusWarning, StatusValidity},
+		{StatusCritical, StatusValidity},
+		{StatusUntrusted, StatusValidity},
+		// an expired intermediate is worse than a leaf not valid yet
+		{StatusExpired, StatusExpired},
+		{StatusRevoked, StatusRevoked},
+	} {
+		r := Result{Status: tt.status}
+		checkValidity(&r, []*x509.Certificate{leaf}, now)
+		if r.Status != tt.want {
+			t.Errorf("checkValidity of a %v result = %v, want %v", tt.status, r.Status, tt.want)
+		}
+	}
+}
+
+// internalChain issues a leaf valid from notBefore to notAfter under a new
+// CA, as private PKI does.
+func internalChain(t *testing.T, notBefore, notAfter time.Time) []*x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Internal CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter.AddDate(1, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "internal.example.com"},
+		DNSNames:     []string{"internal.example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTpl, ca, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chain []*x509.Certificate
+	for _, der := range [][]byte{leafDER, caDER} {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, cert)
+	}
+	return chain
+}
+
+func TestCheckValidityInternalCA(t *testing.T) {
+	now := time.Now()
+	chain := internalChain(t, now.Add(-time.Hour), now.AddDate(10, 0, 0))
+	r := Result{Status: StatusOK}
+	checkValidity(&r, chain, now)
+	if r.Status != StatusOK {
+		t.Errorf("10 year leaf of an internal CA got %v (%s), want %v", r.Status, r.Reason, StatusOK)
+	}
+}
@@ -1,76 +1,162 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
+	"crypto/x509"
 	_ "embed"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	_ "time/tzdata" // timezones also on hosts without a zoneinfo database
 
 	"github.com/BurntSushi/toml"
 )
 
 type Config struct {
-	Version int          `toml:"version"`
-	Groups  []WatchGroup `toml:"groups"`
+	Version     int    `toml:"version"`
+	Lang        string `toml:"lang"`
+	Timezone    string `toml:"timezone"`    // IANA name for dates and schedules, default local time
+	Concurrency int    `toml:"concurrency"` // checks at once over all groups
+	Timeout     int    `toml:"timeout"`     // seconds per dial and per handshake
+	Family      string `toml:"family"`      // IP version to dial, see families
+	Proxy       string `toml:"proxy"`       // proxy to check sites through, see parseProxy
+	Details     bool   `toml:"details"`     // add the certificate's details to alerts
+	CheckRetry
+	Deadline   int            `toml:"deadline"` // seconds per pass, 0 for none
+	Verify     bool           `toml:"verify"`   // verify chains against the system roots
+	OCSP       bool           `toml:"ocsp"`     // ask OCSP responders whether leaves are revoked
+	CTLogs     string         `toml:"ct_logs"`  // CT log list to verify SCTs against
+	Hygiene    bool           `toml:"hygiene"`  // alert on weak keys and signatures
+	DANE       bool           `toml:"dane"`     // hold servers to their TLSA records
+	ARI        bool           `toml:"ari"`      // ask ACME CAs when to renew
+	Ciphers    []string       `toml:"ciphers"`  // cipher suites allowed, default any
+	SSHJump    *SSHJump       `toml:"ssh_jump"` // bastion to check sites through
+	Splay      int            `toml:"splay"`    // seconds, daemon mode only
+	Cooldown   int            `toml:"cooldown"` // seconds before an alert repeats
+	StateFile  string         `toml:"state_file"`
+	NotifyMode string         `toml:"notify_mode"` // "always" or "change"
+	Retry      RetryPolicy    `toml:"retry"`
+	Heartbeat  Heartbeat      `toml:"heartbeat"`
+	RateLimits map[string]int `toml:"rate_limits"`
+	Groups     []WatchGroup   `toml:"groups"`
+	// ACMEDirectories adds to acmeDirectories.
+	ACMEDirectories map[string]string `toml:"acme_directories"`
+	// DuplicateKeys alerts on leaf keys and serials shared between hosts.
+	DuplicateKeys bool `toml:"duplicate_keys"`
+	// SlowHandshake is the milliseconds a TLS handshake may take before
+	// the site is alerted as slow, 0 for no limit.
+	SlowHandshake int `toml:"slow_handshake"`
+	// History is an SQLite database to record every check in, kept for
+	// HistoryDays or for ever.
+	History     string `toml:"history"`
+	HistoryDays int    `toml:"history_days"`
+	// MetricsListen is the address the daemon serves Prometheus metrics
+	// on, such as ":9219".
+	MetricsListen string `toml:"metrics_listen"`
+	// Pushgateway gets the metrics of each pass, see Pushgateway.
+	Pushgateway *Pushgateway `toml:"pushgateway"`
+	// OTel exports traces and metrics over OTLP, see OTel.
+	OTel *OTel `toml:"otel"`
+	// InfluxDB gets a point per check, see InfluxDB.
+	InfluxDB *InfluxDB `toml:"influxdb"`
+
+	slots  chan struct{}
+	ctLogs map[[32]byte]ctLog
+	proxy  *url.URL
+	state  *stateStore
+	daemon bool
+	loc    *time.Location
+
+	renewalInfoURLs *sync.Map // ACME directory URL to its renewalInfo URL
+	history         *historyStore
 }
 
 type WatchGroup struct {
 	Name                string   `toml:"name"`
+	Lang                string   `toml:"lang"`     // overrides the global lang
+	Timezone            string   `toml:"timezone"` // overrides the global timezone
 	WxworkToken         string   `toml:"wxwork_token"`
-	Interval            int      `toml:"interval"`
+	WxworkMsgType       string   `toml:"wxwork_msgtype"`
+	MentionedList       []string `toml:"mentioned_list"`
+	MentionedMobileList []string `toml:"mentioned_mobile_list"`
+	Interval            int      `toml:"interval"` // seconds, daemon mode only
+	Splay               int      `toml:"splay"`    // overrides the global splay
+	Cooldown            int      `toml:"cooldown"` // overrides the global cooldown
+	NotifyMode          string   `toml:"notify_mode"`
+	Concurrency         int      `toml:"concurrency"`
 	DayBeforeExpiration int      `toml:"redline"`
-	Sites               []string `toml:"sites"`
+	RemindAt            []int    `toml:"remind_at"`  // days left to warn at, replaces redline
+	CARedline           int      `toml:"ca_redline"` // redline of the chain's CA certificates
+	Critical            int      `toml:"critical"`   // days left from which expiry is critical, 0 for never
+	Verify              bool     `toml:"verify"`     // also when the global verify is off
+	CABundle            string   `toml:"ca_bundle"`  // PEM roots trusted besides the system ones, implies verify
+	OCSP                *bool    `toml:"ocsp"`       // overrides the global ocsp either way
+	Hygiene             *bool    `toml:"hygiene"`    // overrides the global hygiene either way
+	DANE                *bool    `toml:"dane"`       // overrides the global dane either way
+	ARI                 *bool    `toml:"ari"`        // overrides the global ari either way
+	Details             *bool    `toml:"details"`    // overrides the global details either way
+	Ciphers             []string `toml:"ciphers"`    // replaces the global ciphers
+	AllIPs              bool     `toml:"all_ips"`    // check every site on each IP it resolves to
+	Family              string   `toml:"family"`     // overrides the global family
+	Proxy               string   `toml:"proxy"`      // overrides the global proxy, "direct" for none
+	SSHJump             *SSHJump `toml:"ssh_jump"`   // overrides the global ssh_jump
+	ClientCert
+	Maintenance []Window    `toml:"maintenance"`
+	QuietHours  QuietHours  `toml:"quiet_hours"`
+	DigestAt    string      `toml:"digest_at"`   // "15:04", send one report a day
+	WeeklyAt    string      `toml:"weekly_at"`   // "mon 09:00"
+	WeeklyHTML  string      `toml:"weekly_html"` // directory to also write weekly reports to
+	Heartbeat   Heartbeat   `toml:"heartbeat"`
+	Kubernetes  *Kubernetes `toml:"kubernetes"` // also check the TLS secrets of a cluster
+	CTMonitor   *CTMonitor  `toml:"ct_monitor"` // also search CT logs for unexpected certificates
+	Sites       []Site      `toml:"sites"`
+	Timeout     int         `toml:"timeout"` // seconds
+	// SlowHandshake overrides the global slow_handshake.
+	SlowHandshake int `toml:"slow_handshake"`
+	CheckRetry
+	Hooks
+	SlackWebhookURL   string `toml:"slack_webhook_url"`
+	DingtalkToken     string `toml:"dingtalk_token"`
+	DingtalkSecret    string `toml:"dingtalk_secret"`
+	TelegramBotToken  string `toml:"telegram_bot_token"`
+	TelegramChatID    string `toml:"telegram_chat_id"`
+	DiscordWebhookURL string `toml:"discord_webhook_url"`
+
+	Notifiers []toml.Primitive    `toml:"notifiers"`
+	Routes    map[string][]string `toml:"routes"`
+	Templates MessageTemplates    `toml:"templates"`
+	notifiers []channel
+	routes    map[slog.Level][]channel
+	messages  *messageSet
+	config    *Config
+	held      *heldReport
+	roots     *x509.CertPool // nil for the system roots
+	proxy     *url.URL       // nil for none, or when Proxy is empty for the global one
+	loc       *time.Location
+	digestAt  dailyTime
+	weeklyAt  weeklyTime
 }
 
 //go:embed config.example.toml
 var defaultTemplate string
 
-const WxworkMsgTplInfo = `
-{
-	"msgtype": "text",
-	"text": {
-		"content": "%s"
-	}
-}
-`
-
-func (g *WatchGroup) SendWxwork(msg string, level slog.Level) error {
-	payload := fmt.Sprintf(WxworkMsgTplInfo, msg)
-	if g.WxworkToken == "" {
-		slog.Warn("wxwork_token is empty, skipping wxwork notification", "group", g.Name)
-		return nil
-	}
-	req, err := http.NewRequest("POST", "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="+g.WxworkToken, strings.NewReader(payload))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	slog.Info("post", "url", req.URL.String(), "data", payload)
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		slog.Error("wxwork notification failed", "status_code", resp.StatusCode, "group", g.Name)
-		return fmt.Errorf("wxwork notification failed with status code: %d", resp.StatusCode)
-	}
-	body, _ := io.ReadAll(resp.Body)
-	slog.Info("body", "response", string(body))
-	slog.Info("wxwork notification sent successfully", "group", g.Name, "level", level.String())
-	return nil
-}
-
 func main() {
 	gen := flag.Bool("g", false, "generate default config")
 	conf := flag.String("c", "config.toml", "config file path")
+	daemonMode := flag.Bool("d", false, "run as daemon, checking each group every interval seconds")
+	deadline := flag.Duration("deadline", 0, "bound each pass, e.g. 5m; sites not reached in time are reported as skipped (overrides the config)")
+	lockfile := flag.String("lockfile", "", "hold an exclusive lock on this file while running; exit if another instance holds it")
+	lockwait := flag.Bool("lockwait", false, "with -lockfile, wait for the other instance instead of exiting")
+	textfile := flag.String("textfile", "", "after the run, write Prometheus metrics to crtwtch.prom in this directory for node_exporter's textfile collector")
 	flag.Parse()
 
 	if *gen {
@@ -88,65 +174,238 @@ func main() {
 		}
 		return
 	}
+	if flag.Arg(0) == "service" {
+		if err := serviceCommand(flag.Args()[1:], *conf); err != nil {
+			slog.Error("service:", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *lockfile != "" {
+		lock, err := acquireLock(*lockfile, *lockwait)
+		if errors.Is(err, errLocked) {
+			slog.Warn("another instance is running, exiting", "lockfile", *lockfile)
+			return
+		}
+		if err != nil {
+			slog.Error("failed to lock:", "lockfile", *lockfile, "error", err)
+			os.Exit(1)
+		}
+		defer lock.Close()
+	}
 	if fi, err := os.Stat(*conf); err != nil || fi.IsDir() {
 		slog.Error("config file not found:", "config", *conf)
 		os.Exit(1)
 	}
 
-	config := Config{}
-	_, err := toml.DecodeFile(*conf, &config)
+	load := func() (*Config, error) {
+		config, err := loadConfig(*conf)
+		if err == nil && *deadline > 0 {
+			config.Deadline = int(deadline.Seconds())
+		}
+		return config, err
+	}
+	config, err := load()
 	if err != nil {
 		slog.Error("failed to parse config file:", "error", err)
 		os.Exit(1)
 	}
-	//TODO: daemon(cron) mode. You have to use crond or systemd timer to run periodically
-	for _, group := range config.Groups {
-		slog.Info("watching group:", "name", group.Name)
-		today := time.Now()
-		alerts := make([]string, 0)
-		for _, site := range group.Sites {
-			slog.Info("checking site:", "site", site)
-			expire, err := GetExpirationDate(site)
-			if err != nil {
-				slog.Error("failed to check cert:", "site", site, "error", err)
-				alerts = append(alerts, fmt.Sprintf("❗ 检测失败: %s", site))
-				continue
-			}
-			daysLeft := int(expire.Sub(today).Hours() / 24)
-			slog.Info("site checked:", "site", site, "expire", expire.Format("2006-01-02"), "days_left", daysLeft)
-			if daysLeft <= group.DayBeforeExpiration && daysLeft >= 0 {
-				alerts = append(alerts, fmt.Sprintf("⚠️ 证书即将过期: %s 还有 %d 天 (到期日: %s)", site, daysLeft, expire.Format("2006-01-02")))
-			} else if daysLeft < 0 {
-				alerts = append(alerts, fmt.Sprintf("❗ 证书已过期: %s (到期日: %s)", site, expire.Format("2006-01-02")))
-			}
+	defer config.history.close()
+	if flag.Arg(0) == "history" {
+		if err := historyCommand(config, flag.Args()[1:]); err != nil {
+			slog.Error("history:", "error", err)
+			os.Exit(1)
 		}
-		if len(alerts) <= 0 {
-			slog.Info("no alerts to send")
-			text := fmt.Sprintf("✅ [%s] 组 %s 的证书监控正常，共 %d 个", time.Now().Format("2006-01-02"), group.Name, len(group.Sites))
-			group.SendWxwork(text, slog.LevelInfo)
-		} else {
-			slog.Info("sending alerts", "count", len(alerts))
-			text := fmt.Sprintf("🚨 [%s] 组 %s 的证书监控发现 %d 个问题:\n%s", time.Now().Format("2006-01-02"), group.Name, len(alerts), strings.Join(alerts, "\n"))
-			group.SendWxwork(text, slog.LevelWarn)
+		return
+	}
+	if cmd := flag.Arg(0); cmd == "snooze" || cmd == "unsnooze" {
+		if err := snoozeCommand(config, cmd, flag.Args()[1:]); err != nil {
+			slog.Error(cmd+":", "error", err)
+			os.Exit(1)
 		}
+		return
+	}
+	flushTelemetry, err := setupTelemetry(context.Background(), config.OTel)
+	if err != nil {
+		slog.Error("failed to set up OpenTelemetry:", "error", err)
+		os.Exit(1)
+	}
+	defer flushTelemetry()
+	if *daemonMode {
+		if runAsService(config, load) {
+			return
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		daemon(ctx, config, load)
+		return
+	}
+	ctx, cancel := config.passContext(context.Background())
+	defer cancel()
+	failed := false
+	for i := range config.Groups {
+		if err := config.Groups[i].Run(ctx); err != nil {
+			failed = true
+		}
+	}
+	if *textfile != "" {
+		if err := metrics.writeTextfile(*textfile); err != nil {
+			slog.Error("failed to write the metrics textfile:", "error", err)
+			flushTelemetry()
+			os.Exit(1)
+		}
+	}
+	if failed {
+		flushTelemetry()
+		slog.Error("some notifications could not be delivered")
+		os.Exit(1)
 	}
 }
 
-func GetExpirationDate(host string) (time.Time, error) {
-	// Check certificate expiration date
-	if !strings.Contains(host, ":") {
-		host = host + ":443"
-	}
-	conn, err := tls.Dial("tcp", host, &tls.Config{
-		InsecureSkipVerify: true,
-	})
+// loadLocation loads the named timezone, or returns def when name is empty.
+func loadLocation(name string, def *time.Location) (*time.Location, error) {
+	if name == "" {
+		return def, nil
+	}
+	loc, err := time.LoadLocation(name)
 	if err != nil {
-		return time.Time{}, err
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// passContext bounds one pass of checks by the configured deadline.
+func (config *Config) passContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if config.Deadline <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(config.Deadline)*time.Second)
+}
+
+func loadConfig(path string) (*Config, error) {
+	config := &Config{}
+	md, err := toml.DecodeFile(path, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	config.slots = make(chan struct{}, config.Concurrency)
+	config.renewalInfoURLs = &sync.Map{}
+	config.Retry.setDefaults()
+	if config.loc, err = loadLocation(config.Timezone, time.Local); err != nil {
+		return nil, err
+	}
+	if err := validCiphers(config.Ciphers); err != nil {
+		return nil, fmt.Errorf("ciphers: %w", err)
+	}
+	if err := validFamily(config.Family); err != nil {
+		return nil, err
+	}
+	if config.proxy, err = parseProxy(config.Proxy); err != nil {
+		return nil, err
+	}
+	if config.SSHJump != nil {
+		if err := config.SSHJump.load(); err != nil {
+			return nil, err
+		}
+	}
+	if config.InfluxDB != nil {
+		if err := config.InfluxDB.load(); err != nil {
+			return nil, err
+		}
+	}
+	if config.ctLogs, err = loadCTLogs(config.CTLogs); err != nil {
+		return nil, fmt.Errorf("ct_logs: %w", err)
+	}
+	if config.state, err = loadState(config.StateFile); err != nil {
+		return nil, fmt.Errorf("state file: %w", err)
+	}
+	for i := range config.Groups {
+		group := &config.Groups[i]
+		group.config = config
+		group.held = &heldReport{}
+		if group.loc, err = loadLocation(group.Timezone, config.loc); err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		if err := group.QuietHours.setup(group.loc); err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		group.localizeWindows()
+		group.Sites = expandPorts(group.Sites)
+		if group.roots, err = loadCABundle(group.CABundle); err != nil {
+			return nil, fmt.Errorf("group %s ca_bundle: %w", group.Name, err)
+		}
+		if group.digestAt, err = parseDailyTime(group.DigestAt); err != nil {
+			return nil, fmt.Errorf("group %s digest_at: %w", group.Name, err)
+		}
+		if group.weeklyAt, err = parseWeeklyTime(group.WeeklyAt); err != nil {
+			return nil, fmt.Errorf("group %s weekly_at: %w", group.Name, err)
+		}
+		if mode := group.notifyMode(); mode != "always" && mode != "change" {
+			return nil, fmt.Errorf("group %s: unknown notify_mode %q", group.Name, mode)
+		}
+		if err := validCiphers(group.Ciphers); err != nil {
+			return nil, fmt.Errorf("group %s ciphers: %w", group.Name, err)
+		}
+		if err := validFamily(group.Family); err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		if group.proxy, err = parseProxy(group.Proxy); err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		if group.SSHJump != nil {
+			if err := group.SSHJump.load(); err != nil {
+				return nil, fmt.Errorf("group %s: %w", group.Name, err)
+			}
+		}
+		if err := group.ClientCert.load(); err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		if group.proxyURL() != nil && group.sshJump() != nil {
+			return nil, fmt.Errorf("group %s: proxy and ssh_jump cannot be combined, set proxy = \"direct\"", group.Name)
+		}
+		if group.CTMonitor != nil {
+			if err := group.CTMonitor.validate(); err != nil {
+				return nil, fmt.Errorf("group %s: %w", group.Name, err)
+			}
+		}
+		for _, mark := range group.RemindAt {
+			if mark < 1 {
+				return nil, fmt.Errorf("group %s: remind_at days must be at least 1, got %d", group.Name, mark)
+			}
+		}
+		slices.Sort(group.RemindAt)
+		if err := group.setupNotifiers(md); err != nil {
+			return nil, err
+		}
+		lang := group.Lang
+		if lang == "" {
+			lang = config.Lang
+		}
+		base, err := catalog(lang)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		if group.messages, err = newMessageSet(group.Templates, base); err != nil {
+			return nil, fmt.Errorf("group %s templates: %w", group.Name, err)
+		}
+		group.messages.detailed = config.Details
+		if group.Details != nil {
+			group.messages.detailed = *group.Details
+		}
+	}
+	// only once the whole file is valid, so a failed reload changes nothing
+	// and leaves no database open
+	if config.history, err = openHistory(config.History, config.HistoryDays); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
 	}
-	defer conn.Close()
-	certs := conn.ConnectionState().PeerCertificates
-	if len(certs) == 0 {
-		return time.Time{}, fmt.Errorf("no certificates found")
+	setupRateLimits(config.RateLimits)
+	names := make([]string, len(config.Groups))
+	for i := range config.Groups {
+		names[i] = config.Groups[i].Name
 	}
-	return certs[0].NotAfter, nil
+	metrics.retain(names)
+	return config, nil
 }
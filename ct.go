@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// ctLog is a Certificate Transparency log known from the log list.
+type ctLog struct {
+	operator string
+	key      crypto.PublicKey
+}
+
+// loadCTLogs reads a log list in the v3 JSON format browsers publish, such
+// as https://www.gstatic.com/ct/log_list/v3/log_list.json, keyed by log ID.
+// An empty path returns nil.
+func loadCTLogs(path string) (map[[32]byte]ctLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	type logEntry struct {
+		Key []byte `json:"key"` // DER SubjectPublicKeyInfo
+	}
+	var list struct {
+		Operators []struct {
+			Name      string     `json:"name"`
+			Logs      []logEntry `json:"logs"`
+			TiledLogs []logEntry `json:"tiled_logs"`
+		} `json:"operators"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	logs := make(map[[32]byte]ctLog)
+	for _, op := range list.Operators {
+		for _, l := range append(op.Logs, op.TiledLogs...) {
+			key, err := x509.ParsePKIXPublicKey(l.Key)
+			if err != nil {
+				return nil, fmt.Errorf("log of %s: %w", op.Name, err)
+			}
+			logs[sha256.Sum256(l.Key)] = ctLog{operator: op.Name, key: key}
+		}
+	}
+	if len(logs) == 0 {
+		return nil, fmt.Errorf("%s: no logs found", path)
+	}
+	return logs, nil
+}
+
+// minCTOperators is how many log operators browsers want SCTs from.
+const minCTOperators = 2
+
+// checkCT alerts on publicly trusted certificates that browsers would reject
+// for want of signed certificate timestamps, embedded or sent in the
+// handshake. With a log list the SCTs also have to verify against known
+// logs of enough different operators; without one any SCT will do.
+func (group *WatchGroup) checkCT(r *Result, peer *peerCerts) {
+	chain := peer.chain
+	if verifyChain(chain, nil) != nil {
+		return // CT is only required of the public roots
+	}
+	embedded, err := embeddedSCTs(chain[0])
+	if err != nil {
+		slog.Warn("bad embedded SCT list", "site", r.Site, "error", err)
+	}
+	if len(embedded)+len(peer.scts) == 0 {
+		r.Status, r.Reason = StatusCT, "no signed certificate timestamps"
+		slog.Warn("publicly trusted certificate without SCTs", "site", r.Site)
+		return
+	}
+	logs := group.config.ctLogs
+	if logs == nil {
+		return
+	}
+	operators := make(map[string]bool)
+	var errs []error
+	verify := func(scts [][]byte, precert bool) {
+		for _, raw := range scts {
+			op, err := verifySCT(raw, chain, precert, logs)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			operators[op] = true
+		}
+	}
+	verify(embedded, true)
+	verify(peer.scts, false)
+	if len(operators) < minCTOperators {
+		r.Status = StatusCT
+		r.Reason = fmt.Sprintf("valid SCTs from %d log operator(s), browsers require %d", len(operators), minCTOperators)
+		if len(errs) > 0 {
+			r.Reason += ": " + errors.Join(errs...).Error()
+		}
+		slog.Warn("not enough valid SCTs", "site", r.Site, "reason", r.Reason)
+	}
+}
+
+// oidSCTList is the extension precertificate SCTs are embedded in.
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// embeddedSCTs returns the SCTs embedded in cert, each still TLS encoded.
+func embeddedSCTs(cert *x509.Certificate) ([][]byte, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) {
+			continue
+		}
+		var octets []byte
+		if _, err := asn1.Unmarshal(ext.Value, &octets); err != nil {
+			return nil, err
+		}
+		list := cryptobyte.String(octets)
+		var items cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&items) || !list.Empty() {
+			return nil, errBadSCT
+		}
+		var scts [][]byte
+		for !items.Empty() {
+			var sct cryptobyte.String
+			if !items.ReadUint16LengthPrefixed(&sct) {
+				return nil, errBadSCT
+			}
+			scts = append(scts, sct)
+		}
+		return scts, nil
+	}
+	return nil, nil
+}
+
+var errBadSCT = errors.New("malformed SCT")
+
+// verifySCT checks the signature of an RFC 6962 SCT over the leaf of chain,
+// as a precertificate entry for embedded SCTs, and returns the operator of
+// the log that issued it.
+func verifySCT(raw []byte, chain []*x509.Certificate, precert bool, logs map[[32]byte]ctLog) (string, error) {
+	s := cryptobyte.String(raw)
+	var version, hashAlg, sigAlg uint8
+	var logID [32]byte
+	var timestamp uint64
+	var exts, sig cryptobyte.String
+	if !s.ReadUint8(&version) || !s.CopyBytes(logID[:]) || !s.ReadUint64(&timestamp) ||
+		!s.ReadUint16LengthPrefixed(&exts) || !s.ReadUint8(&hashAlg) || !s.ReadUint8(&sigAlg) ||
+		!s.ReadUint16LengthPrefixed(&sig) || !s.Empty() {
+		return "", errBadSCT
+	}
+	if version != 0 {
+		return "", fmt.Errorf("unsupported SCT version %d", version)
+	}
+	log, ok := logs[logID]
+	if !ok {
+		return "", fmt.Errorf("SCT from an unknown log %x", logID[:8])
+	}
+	leaf := chain[0]
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(version)
+	b.AddUint8(0) // certificate_timestamp
+	b.AddUint64(timestamp)
+	if precert {
+		issuer := chainIssuer(chain)
+		if issuer == nil {
+			return "", errors.New("embedded SCTs need the issuer in the chain")
+		}
+		tbs, err := precertTBS(leaf)
+		if err != nil {
+			return "", err
+		}
+		keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+		b.AddUint16(1) // precert_entry
+		b.AddBytes(keyHash[:])
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(tbs) })
+	} else {
+		b.AddUint16(0) // x509_entry
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(leaf.Raw) })
+	}
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(exts) })
+	signed, err := b.Bytes()
+	if err != nil {
+		return "", err
+	}
+	if hashAlg != 4 { // sha256
+		return "", fmt.Errorf("SCT from %s: unsupported hash algorithm %d", log.operator, hashAlg)
+	}
+	digest := sha256.Sum256(signed)
+	valid := false
+	switch key := log.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+	if !valid {
+		return "", fmt.Errorf("SCT from %s: bad signature", log.operator)
+	}
+	return log.operator, nil
+}
+
+// precertTBS rebuilds the TBSCertificate of the precertificate cert was
+// issued from, which is cert's without the SCT list extension.
+func precertTBS(cert *x509.Certificate) ([]byte, error) {
+	input := cryptobyte.String(cert.RawTBSCertificate)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cbasn1.SEQUENCE) {
+		return nil, errors.New("malformed TBSCertificate")
+	}
+	extsTag := cbasn1.Tag(3).ContextSpecific().Constructed()
+	b := cryptobyte.NewBuilder(nil)
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var field cryptobyte.String
+			var tag cbasn1.Tag
+			if !tbs.ReadAnyASN1Element(&field, &tag) {
+				b.SetError(errors.New("malformed TBSCertificate"))
+				return
+			}
+			var wrapped, exts cryptobyte.String
+			if f := field; tag != extsTag || !f.ReadASN1(&wrapped, extsTag) || !wrapped.ReadASN1(&exts, cbasn1.SEQUENCE) {
+				b.AddBytes(field)
+				continue
+			}
+			b.AddASN1(extsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for !exts.Empty() {
+						var ext cryptobyte.String
+						if !exts.ReadASN1Element(&ext, cbasn1.SEQUENCE) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						var body cryptobyte.String
+						var oid asn1.ObjectIdentifier
+						if e := ext; e.ReadASN1(&body, cbasn1.SEQUENCE) && body.ReadASN1ObjectIdentifier(&oid) && oid.Equal(oidSCTList) {
+							continue
+						}
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+	return b.Bytes()
+}
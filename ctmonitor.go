@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CTMonitor has a group search the Certificate Transparency logs, through
+// crt.sh, for certificates of its domains that were issued by a CA not on
+// its list, such as misissued or shadow IT ones. Each unexpected
+// certificate that has not expired is reported as the site
+// "ct://domain/id", id being its crt.sh ID.
+type CTMonitor struct {
+	Domains []string `toml:"domains"` // subdomains are included
+	// Issuers are the CAs allowed to issue for the domains, each matched
+	// case-insensitively anywhere in the issuer DN, as in "O=Let's Encrypt".
+	Issuers []string `toml:"issuers"`
+	URL     string   `toml:"url"`     // default https://crt.sh
+	Timeout int      `toml:"timeout"` // seconds per query, default 60
+}
+
+const defaultCTMonitorURL = "https://crt.sh"
+
+func (m *CTMonitor) validate() error {
+	if len(m.Domains) == 0 || len(m.Issuers) == 0 {
+		return fmt.Errorf("ct_monitor needs domains and issuers")
+	}
+	return nil
+}
+
+// crtshEntry is a certificate as crt.sh lists it.
+type crtshEntry struct {
+	ID         int64  `json:"id"`
+	IssuerName string `json:"issuer_name"`
+	NameValue  string `json:"name_value"` // newline separated
+	NotAfter   string `json:"not_after"`  // UTC, without a zone
+}
+
+// checkCTLogs reports the certificates of the group's domains from issuers
+// off its list. A domain that could not be searched is a failed site
+// "ct://domain".
+func (group *WatchGroup) checkCTLogs(ctx context.Context, today time.Time) []Result {
+	m := group.CTMonitor
+	timeout := 60 * time.Second
+	if m.Timeout > 0 {
+		timeout = time.Duration(m.Timeout) * time.Second
+	}
+	client := group.httpClient(timeout)
+	var results []Result
+	for _, domain := range m.Domains {
+		entries, err := m.search(ctx, client, domain)
+		if err != nil {
+			site := "ct://" + domain
+			slog.Error("failed to search the CT logs:", "site", site, "error", err)
+			results = append(results, Result{Site: site, Status: StatusFailed, Err: &checkError{Phase: "search", Err: err}, Attempts: 1, messages: group.messages})
+			continue
+		}
+		for _, e := range entries {
+			if m.allows(e.IssuerName) {
+				continue
+			}
+			r := Result{Site: "ct://" + domain + "/" + strconv.FormatInt(e.ID, 10), Issuer: e.IssuerName, Status: StatusUnexpectedIssuer, Attempts: 1, messages: group.messages}
+			if t, err := time.Parse("2006-01-02T15:04:05", e.NotAfter); err == nil {
+				r.Expiry = t.In(group.loc)
+				r.Left = r.Expiry.Sub(today)
+				r.DaysLeft = daysOf(r.Left)
+			}
+			r.Reason = fmt.Sprintf("%s by %s", strings.ReplaceAll(e.NameValue, "\n", ", "), e.IssuerName)
+			slog.Warn("certificate from an unexpected issuer", "site", r.Site, "names", e.NameValue, "issuer", e.IssuerName)
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+func (m *CTMonitor) allows(issuer string) bool {
+	issuer = strings.ToLower(issuer)
+	for _, allowed := range m.Issuers {
+		if strings.Contains(issuer, strings.ToLower(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// search returns the unexpired certificates crt.sh knows for domain and its
+// subdomains, once each.
+func (m *CTMonitor) search(ctx context.Context, client *http.Client, domain string) ([]crtshEntry, error) {
+	base := m.URL
+	if base == "" {
+		base = defaultCTMonitorURL
+	}
+	seen := make(map[int64]bool)
+	var entries []crtshEntry
+	for _, q := range []string{domain, "%." + domain} {
+		query := url.Values{"q": {q}, "output": {"json"}, "exclude": {"expired"}, "deduplicate": {"Y"}}
+		body, err := fetchURL(ctx, client, strings.TrimSuffix(base, "/")+"/?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var found []crtshEntry
+		if err := json.Unmarshal(body, &found); err != nil {
+			return nil, fmt.Errorf("bad response: %w", err)
+		}
+		for _, e := range found {
+			if !seen[e.ID] {
+				seen[e.ID] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultInterval = 24 * time.Hour
+
+// daemon runs every group on its own ticker until ctx is done. A pass that
+// is in flight at that point is allowed to finish.
+// SIGHUP reloads the config with load; groups that survive the reload keep
+// their schedule, and a config that fails to load leaves the old one running.
+// Under systemd the daemon reports its state and feeds the watchdog.
+func daemon(ctx context.Context, config *Config, load func() (*Config, error)) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go sdWatchdog(ctx)
+	metricsServer := &metricsListener{}
+	defer metricsServer.stop()
+	sdNotify("READY=1")
+	sched := &schedule{last: map[string]time.Time{}}
+	for {
+		config.daemon = true
+		metricsServer.listen(ctx, config.MetricsListen)
+		runCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for i := range config.Groups {
+			group := &config.Groups[i]
+			wg.Go(func() { group.watch(runCtx, sched) })
+		}
+		next := waitReload(ctx, hup, config, load)
+		if next == nil {
+			sdNotify("STOPPING=1")
+		}
+		cancel()
+		wg.Wait()
+		config.history.close()
+		config.closeSSHJumps()
+		if next == nil {
+			break
+		}
+		config = next
+		sdNotify("READY=1")
+	}
+	slog.Info("daemon stopped")
+}
+
+// waitReload blocks until SIGHUP brings a config that loads, and returns it,
+// or until ctx is done, and returns nil.
+func waitReload(ctx context.Context, hup <-chan os.Signal, config *Config, load func() (*Config, error)) *Config {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+		}
+		slog.Info("reloading config")
+		sdNotify("RELOADING=1")
+		next, err := load()
+		if err != nil {
+			slog.Error("failed to reload config, keeping the current one", "error", err)
+			sdNotify("READY=1")
+			continue
+		}
+		logConfigDiff(config, next)
+		if next.StateFile == config.StateFile {
+			next.state = config.state // keeps what is only in memory
+		}
+		return next
+	}
+}
+
+// logConfigDiff logs the groups added and removed by a reload and, for the
+// groups kept, the sites added and removed and whether other settings changed.
+func logConfigDiff(old, next *Config) {
+	if !reflect.DeepEqual(configSettings(old), configSettings(next)) {
+		slog.Info("config reloaded: global settings changed")
+	}
+	oldGroups := make(map[string]*WatchGroup, len(old.Groups))
+	for i := range old.Groups {
+		oldGroups[old.Groups[i].Name] = &old.Groups[i]
+	}
+	for i := range next.Groups {
+		group := &next.Groups[i]
+		prev, ok := oldGroups[group.Name]
+		if !ok {
+			slog.Info("config reloaded: group added", "group", group.Name, "sites", len(group.Sites))
+			continue
+		}
+		delete(oldGroups, group.Name)
+		if added := siteDiff(group.Sites, prev.Sites); len(added) > 0 {
+			slog.Info("config reloaded: sites added", "group", group.Name, "sites", added)
+		}
+		if removed := siteDiff(prev.Sites, group.Sites); len(removed) > 0 {
+			slog.Info("config reloaded: sites removed", "group", group.Name, "sites", removed)
+		}
+		if !reflect.DeepEqual(groupSettings(prev), groupSettings(group)) {
+			slog.Info("config reloaded: group settings changed", "group", group.Name)
+		}
+	}
+	for name := range oldGroups {
+		slog.Info("config reloaded: group removed", "group", name)
+	}
+}
+
+// configSettings is config without its groups and runtime state.
+func configSettings(config *Config) Config {
+	c := *config
+	c.Groups, c.slots, c.state, c.history, c.daemon, c.loc, c.renewalInfoURLs = nil, nil, nil, nil, false, nil, nil
+	return c
+}
+
+// groupSettings is group without its sites and runtime state.
+func groupSettings(group *WatchGroup) WatchGroup {
+	g := *group
+	g.Sites = nil
+	g.notifiers, g.routes, g.messages, g.config, g.held, g.loc = nil, nil, nil, nil, nil, nil
+	g.QuietHours = QuietHours{From: g.QuietHours.From, To: g.QuietHours.To, Timezone: g.QuietHours.Timezone}
+	return g
+}
+
+// siteDiff returns the addresses of sites in a that are not in b.
+func siteDiff(a, b []Site) []string {
+	var diff []string
+	for _, site := range a {
+		if !slices.ContainsFunc(b, func(s Site) bool { return s.Addr == site.Addr }) {
+			diff = append(diff, site.Addr)
+		}
+	}
+	return diff
+}
+
+// schedule remembers when each group last ran, by name, across reloads.
+type schedule struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (s *schedule) lastRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.last[name]
+	return t, ok
+}
+
+func (s *schedule) ran(name string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[name] = t
+}
+
+// nextTimed returns the next digest or weekly report time of the group, if
+// it has either.
+func (group *WatchGroup) nextTimed() (time.Time, bool) {
+	now := time.Now().In(group.loc)
+	var next []time.Time
+	if group.digestAt.enabled {
+		next = append(next, group.digestAt.next(now))
+	}
+	if group.weeklyAt.enabled {
+		next = append(next, group.weeklyAt.next(now))
+	}
+	if len(next) == 0 {
+		return time.Time{}, false
+	}
+	return slices.MinFunc(next, time.Time.Compare), true
+}
+
+func (group *WatchGroup) interval() time.Duration {
+	if group.Interval <= 0 {
+		return defaultInterval
+	}
+	return time.Duration(group.Interval) * time.Second
+}
+
+// splay returns a random delay within the group's splay window, so groups
+// and instances started together do not check and notify all at once.
+func (group *WatchGroup) splay() time.Duration {
+	window := group.config.Splay
+	if group.Splay > 0 {
+		window = group.Splay
+	}
+	if window <= 0 {
+		return 0
+	}
+	return rand.N(time.Duration(window) * time.Second)
+}
+
+// watch runs the group once after its splay delay, or when its interval is
+// up if it already ran before a reload, and then once per interval until ctx
+// is done.
+func (group *WatchGroup) watch(ctx context.Context, sched *schedule) {
+	interval := group.interval()
+	delay := group.splay()
+	if last, ok := sched.lastRun(group.Name); ok {
+		delay = max(0, time.Until(last.Add(interval)))
+	}
+	slog.Info("scheduling group:", "name", group.Name, "interval", interval.String(), "delay", delay.Round(time.Second).String())
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// also run at the digest and weekly report times so they go out on time
+	var timed *time.Timer
+	var timedC <-chan time.Time
+	if next, ok := group.nextTimed(); ok {
+		timed = time.NewTimer(time.Until(next))
+		defer timed.Stop()
+		timedC = timed.C
+	}
+	for {
+		sched.ran(group.Name, time.Now())
+		// a pass that is in flight when the signal comes may finish
+		passCtx, cancel := group.config.passContext(context.WithoutCancel(ctx))
+		_ = group.Run(passCtx) // failures are logged per channel
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-timedC:
+			next, _ := group.nextTimed()
+			timed.Reset(time.Until(next))
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// checksDANE reports whether the group holds its servers to their TLSA
+// records: its own dane setting if it has one, else the global one.
+func (group *WatchGroup) checksDANE() bool {
+	if group.DANE != nil {
+		return *group.DANE
+	}
+	return group.config.DANE
+}
+
+// tlsaRecord is a TLSA record (RFC 6698) naming a certificate or public key
+// a server may present.
+type tlsaRecord struct {
+	usage, selector, matching uint8
+	data                      []byte
+}
+
+func (t tlsaRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", t.usage, t.selector, t.matching, hex.EncodeToString(t.data))
+}
+
+// matches reports whether cert is the one t names.
+func (t tlsaRecord) matches(cert *x509.Certificate) bool {
+	data := cert.Raw
+	if t.selector == 1 {
+		data = cert.RawSubjectPublicKeyInfo
+	}
+	switch t.matching {
+	case 1:
+		sum := sha256.Sum256(data)
+		data = sum[:]
+	case 2:
+		sum := sha512.Sum512(data)
+		data = sum[:]
+	}
+	return bytes.Equal(data, t.data)
+}
+
+// tlsaRecords returns the TLSA records of site with their name, none for
+// sites without a host name. A failed lookup is logged and returns none,
+// since a flaky resolver says nothing about the certificate. Whether the
+// records are signed is up to the resolver.
+func (group *WatchGroup) tlsaRecords(ctx context.Context, site *Site) (string, []tlsaRecord) {
+	host := site.serverName()
+	if host == "" {
+		return "", nil
+	}
+	proto, addr, _ := siteTarget(site.Addr)
+	_, port, _ := net.SplitHostPort(addr)
+	transport := "_tcp."
+	if proto.handshake != nil { // QUIC
+		transport = "_udp."
+	}
+	name := "_" + port + "." + transport + host
+	records, err := lookupTLSA(ctx, name, group.timeout(site))
+	if err != nil {
+		slog.Warn("failed to look up TLSA records", "site", site, "name", name, "error", err)
+		return "", nil
+	}
+	return name, records
+}
+
+// checkDANE marks r when the site has TLSA records and chain satisfies none
+// of them, which makes DANE-validating clients such as MTAs refuse the
+// server.
+func (group *WatchGroup) checkDANE(r *Result, name string, records []tlsaRecord, chain []*x509.Certificate) {
+	if len(records) == 0 || group.daneMatches(records, chain) {
+		return
+	}
+	r.Status, r.Reason = StatusDANE, fmt.Sprintf("no TLSA record of %s matches (%d records)", name, len(records))
+	slog.Warn("certificate does not match the TLSA records", "site", r.Site, "name", name, "leaf", hex.EncodeToString(sha256Sum(chain[0].RawSubjectPublicKeyInfo)))
+}
+
+// daneMatches reports whether chain satisfies one of records.
+func (group *WatchGroup) daneMatches(records []tlsaRecord, chain []*x509.Certificate) bool {
+	for _, t := range records {
+		if daneSatisfied(t, chain, group.roots) {
+			return true
+		}
+	}
+	return false
+}
+
+// daneSatisfied reports whether chain satisfies t: the leaf for the
+// end-entity usages, a CA certificate for the trust anchor ones, and for
+// the PKIX usages a chain that verifies as well.
+func daneSatisfied(t tlsaRecord, chain []*x509.Certificate, roots *x509.CertPool) bool {
+	certs := chain[:1]
+	if t.usage == 0 || t.usage == 2 {
+		certs = chain[1:]
+	}
+	if t.usage < 2 && verifyChain(chain, roots) != nil {
+		return false
+	}
+	for _, cert := range certs {
+		if t.matches(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+const typeTLSA = 52
+
+// lookupTLSA asks the first nameserver of /etc/resolv.conf for the TLSA
+// records of name, over UDP and again over TCP when the answer does not fit.
+// A name that does not exist has no records.
+func lookupTLSA(ctx context.Context, name string, timeout time.Duration) ([]tlsaRecord, error) {
+	query, id, err := dnsQuery(name, typeTLSA)
+	if err != nil {
+		return nil, err
+	}
+	server := nameserver()
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp, err := dnsExchange(dialCtx, "udp", server, query)
+	if err == nil && len(resp) > 2 && resp[2]&0x02 != 0 { // truncated
+		resp, err = dnsExchange(dialCtx, "tcp", server, query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseTLSA(resp, id)
+}
+
+// nameserver returns the first nameserver of /etc/resolv.conf.
+func nameserver() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err == nil {
+		defer f.Close()
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			if fields := strings.Fields(s.Text()); len(fields) >= 2 && fields[0] == "nameserver" {
+				return withPort(fields[1], "53")
+			}
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+// dnsQuery builds a recursive query for name and type with room for large
+// UDP answers (EDNS0), returning it with its ID.
+func dnsQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	var idBytes [2]byte
+	rand.Read(idBytes[:])
+	id := binary.BigEndian.Uint16(idBytes[:])
+	msg := binary.BigEndian.AppendUint16(nil, id)
+	msg = append(msg, 0x01, 0x20)             // recursion desired, authenticated data
+	msg = append(msg, 0, 1, 0, 0, 0, 0, 0, 1) // one question, one additional
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, 0, fmt.Errorf("bad DNS name %q", name)
+		}
+		msg = append(append(msg, byte(len(label))), label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = append(msg, 0, 1)                                   // class IN
+	msg = append(msg, 0, 0, 41, 0x04, 0xd0, 0, 0, 0, 0, 0, 0) // OPT, 1232-byte payload
+	return msg, id, nil
+}
+
+func dnsExchange(ctx context.Context, network, server string, query []byte) ([]byte, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		return buf[:n], err
+	}
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err = io.ReadFull(conn, resp)
+	return resp, err
+}
+
+var errBadDNS = errors.New("malformed DNS response")
+
+// parseTLSA returns the TLSA records answering the query with id.
+func parseTLSA(msg []byte, id uint16) ([]tlsaRecord, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg) != id {
+		return nil, errBadDNS
+	}
+	switch rcode := msg[3] & 0x0f; rcode {
+	case 0:
+	case 3: // NXDOMAIN
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("DNS error code %d", rcode)
+	}
+	qdcount, ancount := binary.BigEndian.Uint16(msg[4:]), binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	var ok bool
+	for range qdcount {
+		if off, ok = skipName(msg, off); !ok || off+4 > len(msg) {
+			return nil, errBadDNS
+		}
+		off += 4
+	}
+	var records []tlsaRecord
+	for range ancount {
+		if off, ok = skipName(msg, off); !ok || off+10 > len(msg) {
+			return nil, errBadDNS
+		}
+		rtype, rdlen := binary.BigEndian.Uint16(msg[off:]), int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+rdlen > len(msg) {
+			return nil, errBadDNS
+		}
+		if rdata := msg[off : off+rdlen]; rtype == typeTLSA && len(rdata) > 3 {
+			records = append(records, tlsaRecord{usage: rdata[0], selector: rdata[1], matching: rdata[2], data: rdata[3:]})
+		}
+		off += rdlen
+	}
+	return records, nil
+}
+
+// skipName returns the offset after the possibly compressed name at off.
+func skipName(msg []byte, off int) (int, bool) {
+	for off < len(msg) {
+		switch n := int(msg[off]); {
+		case n == 0:
+			return off + 1, true
+		case n&0xc0 == 0xc0: // pointer, which ends the name
+			return off + 2, off+2 <= len(msg)
+		default:
+			off += 1 + n
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// dailyTime is a time of day such as "09:00", in the group's timezone.
+type dailyTime struct {
+	enabled bool
+	since   time.Duration // since midnight
+}
+
+func parseDailyTime(s string) (dailyTime, error) {
+	if s == "" {
+		return dailyTime{}, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return dailyTime{}, fmt.Errorf("bad time %q, want HH:MM", s)
+	}
+	return dailyTime{enabled: true, since: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}, nil
+}
+
+// on returns the time of day on the day of t.
+func (d dailyTime) on(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(d.since)
+}
+
+// next returns the first time of day after t.
+func (d dailyTime) next(t time.Time) time.Time {
+	at := d.on(t)
+	if !at.After(t) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// digest handles a pass of a group in digest mode: the results are kept
+// quiet, except that the first pass at or after the digest time of day sends
+// them all in one report, sorted by days left.
+func (group *WatchGroup) digest(rep *Report) error {
+	state := group.config.state
+	state.mu.Lock()
+	last := state.Digests[group.Name]
+	state.mu.Unlock()
+	at := group.digestAt.on(rep.Date)
+	if rep.Date.Before(at) || !last.Before(at) {
+		slog.Info("digest mode, not sending until the digest time", "group", group.Name, "alerts", len(rep.Alerts()), "next", group.digestAt.next(rep.Date).Format(time.DateTime))
+		return nil
+	}
+	out := *rep
+	out.listAll = true
+	out.Results = slices.Clone(rep.Results)
+	slices.SortStableFunc(out.Results, func(a, b Result) int {
+		// sites without a certificate first, they are the least known
+		if (a.Err != nil) != (b.Err != nil) {
+			if a.Err != nil {
+				return -1
+			}
+			return 1
+		}
+		return a.DaysLeft - b.DaysLeft
+	})
+	slog.Info("sending the daily digest", "group", group.Name, "sites", len(out.Results))
+	if err := group.notify(&out); err != nil {
+		return err
+	}
+	group.recordState(&out)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Digests[group.Name] = rep.Date
+	state.save()
+	return nil
+}
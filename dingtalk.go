@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterNotifier("dingtalk", func(decode func(any) error) (Notifier, error) {
+		n := &DingtalkNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.Token == "" {
+			return nil, fmt.Errorf("dingtalk: token is required")
+		}
+		return n, nil
+	})
+}
+
+// DingtalkNotifier posts markdown messages to a DingTalk custom robot.
+// Secret is only needed when the robot uses the "加签" security setting.
+type DingtalkNotifier struct {
+	Token  string `toml:"token"`
+	Secret string `toml:"secret"`
+}
+
+type dingtalkMessage struct {
+	MsgType  string `json:"msgtype"`
+	Markdown struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	} `json:"markdown"`
+}
+
+type dingtalkResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// dingtalkSign computes the robot signature of timestamp (milliseconds).
+func dingtalkSign(timestamp int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (n *DingtalkNotifier) webhookURL() string {
+	q := url.Values{"access_token": {n.Token}}
+	if n.Secret != "" {
+		ts := time.Now().UnixMilli()
+		q.Set("timestamp", strconv.FormatInt(ts, 10))
+		q.Set("sign", dingtalkSign(ts, n.Secret))
+	}
+	return "https://oapi.dingtalk.com/robot/send?" + q.Encode()
+}
+
+func (n *DingtalkNotifier) Send(msg string, level slog.Level) error {
+	title, _, _ := strings.Cut(msg, "\n")
+	return n.post(title, msg)
+}
+
+func (n *DingtalkNotifier) SendReport(rep *Report) error {
+	lines := []string{"### " + rep.Title()}
+	for _, r := range rep.Shown() {
+		lines = append(lines, "- "+r.Message())
+	}
+	return n.post(rep.Title(), strings.Join(lines, "\n"))
+}
+
+func (n *DingtalkNotifier) post(title, text string) error {
+	msg := dingtalkMessage{MsgType: "markdown"}
+	msg.Markdown.Title = title
+	msg.Markdown.Text = text
+	throttle("dingtalk")
+	body, err := postJSON(n.webhookURL(), msg)
+	if err != nil {
+		return fmt.Errorf("dingtalk: %w", err)
+	}
+	var resp dingtalkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("dingtalk: bad response: %w", err)
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("dingtalk: errcode %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+func init() {
+	RegisterNotifier("discord", func(decode func(any) error) (Notifier, error) {
+		n := &DiscordNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.WebhookURL == "" {
+			return nil, fmt.Errorf("discord: webhook_url is required")
+		}
+		return n, nil
+	})
+}
+
+// DiscordNotifier posts to a Discord channel webhook with one embed per alert.
+type DiscordNotifier struct {
+	WebhookURL string `toml:"webhook_url"`
+}
+
+// discordMaxEmbeds is how many embeds Discord accepts in one message.
+const discordMaxEmbeds = 10
+
+type discordMessage struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title  string         `json:"title"`
+	Color  int            `json:"color"`
+	Fields []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func discordColor(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 0xe74c3c
+	case level >= slog.LevelWarn:
+		return 0xf1c40f
+	}
+	return 0x2ecc71
+}
+
+func discordEmbedOf(r *Result) discordEmbed {
+	l := r.labels()
+	e := discordEmbed{Title: r.Message(), Color: discordColor(r.Status.Level())}
+	e.Fields = append(e.Fields, discordField{Name: l.Site, Value: r.Site, Inline: true})
+	if r.Err != nil {
+		e.Fields = append(e.Fields, discordField{Name: l.Error, Value: r.Err.Error()})
+		return e
+	}
+	leftLabel, left := r.timeLeft()
+	e.Fields = append(e.Fields,
+		discordField{Name: l.Expiry, Value: r.Expiry.Format("2006-01-02"), Inline: true},
+		discordField{Name: leftLabel, Value: strconv.Itoa(left), Inline: true},
+		discordField{Name: l.Issuer, Value: r.Issuer},
+	)
+	return e
+}
+
+func (n *DiscordNotifier) Send(msg string, level slog.Level) error {
+	return n.post(discordMessage{Content: msg})
+}
+
+// SendReport splits the embeds over as many messages as Discord requires;
+// only the first one carries the title.
+func (n *DiscordNotifier) SendReport(rep *Report) error {
+	shown := rep.Shown()
+	if len(shown) == 0 {
+		return n.Send(rep.Title(), slog.LevelInfo)
+	}
+	msg := discordMessage{Content: rep.Title()}
+	for i := range shown {
+		msg.Embeds = append(msg.Embeds, discordEmbedOf(&shown[i]))
+		if len(msg.Embeds) == discordMaxEmbeds {
+			if err := n.post(msg); err != nil {
+				return err
+			}
+			msg = discordMessage{}
+		}
+	}
+	if len(msg.Embeds) == 0 {
+		return nil
+	}
+	return n.post(msg)
+}
+
+func (n *DiscordNotifier) post(msg discordMessage) error {
+	status, body, err := sendJSON("POST", n.WebhookURL, nil, msg)
+	if err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	if status != 200 && status != 204 {
+		return fmt.Errorf("discord: unexpected status code %d: %s", status, body)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// duplicateWindow is how long a site's key is compared against after it was
+// last seen, so sites taken out of the config stop matching.
+const duplicateWindow = 7 * 24 * time.Hour
+
+// checkDuplicates marks the results of rep whose leaf key, or issuer and
+// serial, was also seen on another host in any group, as happens when a
+// wildcard key is copied around. It compares against the state that observe
+// recorded, so sites of groups that run later are only compared on their
+// next run; cron runs need the state file for it. Sites whose key is meant
+// to be shared set shared_key. Like hygiene it only replaces OK.
+func (group *WatchGroup) checkDuplicates(rep *Report) {
+	if !group.config.DuplicateKeys {
+		return
+	}
+	groups := make(map[string]bool, len(group.config.Groups))
+	for _, g := range group.config.Groups {
+		groups[g.Name] = true
+	}
+	state := group.config.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for i := range rep.Results {
+		r := &rep.Results[i]
+		if r.Status != StatusOK || r.keyPin == "" || group.site(r.addr()).SharedKey {
+			continue
+		}
+		var keyHosts, serialHosts []string
+		for key, st := range state.Sites {
+			g, _, _ := strings.Cut(key, "/")
+			if st.Host == r.host || !groups[g] || rep.Date.Sub(st.Seen) > duplicateWindow || st.SharedKey {
+				continue
+			}
+			switch {
+			case st.KeyPin == r.keyPin:
+				keyHosts = append(keyHosts, st.Host)
+			case st.Serial == r.Serial && st.Issuer == r.IssuerDN:
+				serialHosts = append(serialHosts, st.Host)
+			}
+		}
+		if len(keyHosts) > 0 {
+			slices.Sort(keyHosts)
+			r.Status, r.Reason = StatusDuplicate, "key also used by "+strings.Join(slices.Compact(keyHosts), ", ")
+		} else if len(serialHosts) > 0 {
+			slices.Sort(serialHosts)
+			r.Status, r.Reason = StatusDuplicate, "issuer and serial also used by "+strings.Join(slices.Compact(serialHosts), ", ")
+		} else {
+			continue
+		}
+		slog.Warn("certificate shared with other hosts", "site", r.Site, "reason", r.Reason)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterNotifier("email", func(decode func(any) error) (Notifier, error) {
+		n := &EmailNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.Host == "" || n.From == "" || len(n.To) == 0 {
+			return nil, fmt.Errorf("email: host, from and to are required")
+		}
+		switch n.Security {
+		case "", "starttls", "tls", "none":
+		default:
+			return nil, fmt.Errorf("email: unknown security %q", n.Security)
+		}
+		if n.Port == 0 {
+			n.Port = 587
+			if n.Security == "tls" {
+				n.Port = 465
+			}
+		}
+		return n, nil
+	})
+}
+
+// EmailNotifier sends a multipart (plain text + HTML) digest over SMTP.
+// Security is "starttls" (default), "tls" for implicit TLS, or "none".
+type EmailNotifier struct {
+	Host     string   `toml:"host"`
+	Port     int      `toml:"port"`
+	Username string   `toml:"username"`
+	Password string   `toml:"password"`
+	Security string   `toml:"security"`
+	From     string   `toml:"from"`
+	To       []string `toml:"to"`
+}
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><body>
+<h3>{{.Title}}</h3>
+<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+{{with .Labels}}<tr><th>{{.Site}}</th><th>{{.Status}}</th><th>{{.Expiry}}</th><th>{{.DaysLeft}}</th></tr>{{end}}
+{{range .Rows}}<tr style="background:{{.Color}}"><td>{{.Site}}</td><td>{{.Status}}</td><td>{{.Expire}}</td><td>{{.DaysLeft}}</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+type emailRow struct {
+	Site, Status, Expire, DaysLeft, Color string
+}
+
+func emailRowOf(r *Result) emailRow {
+	row := emailRow{Site: r.Site, Expire: "-", DaysLeft: "-"}
+	if r.Err == nil {
+		row.Expire = r.Expiry.Format("2006-01-02")
+		row.DaysLeft = r.timeLeftCell()
+	}
+	row.Status = r.labels().Statuses[r.Status]
+	switch r.Status {
+	case StatusFailed, StatusCritical, StatusExpired, StatusUntrusted, StatusMismatch, StatusRevoked, StatusStaple, StatusCT, StatusUnexpectedIssuer, StatusPinMismatch, StatusTLSPolicy, StatusSelfSigned, StatusValidity, StatusDANE, StatusDrift:
+		row.Color = "#f8d7da"
+	case StatusWarning, StatusSkipped, StatusOCSPFailed, StatusHygiene, StatusRenewalDue, StatusDuplicate, StatusSlow:
+		row.Color = "#fff3cd"
+	default:
+		row.Color = "#ffffff"
+	}
+	return row
+}
+
+func (n *EmailNotifier) Send(msg string, level slog.Level) error {
+	subject, _, _ := strings.Cut(msg, "\n")
+	return n.send(subject, msg, "")
+}
+
+// SendReport mails every site of the group, not only the alerts, so the
+// digest doubles as an inventory.
+func (n *EmailNotifier) SendReport(rep *Report) error {
+	data := struct {
+		Title  string
+		Labels *labelSet
+		Rows   []emailRow
+	}{Title: rep.Title(), Labels: rep.labels()}
+	text := []string{rep.Title(), ""}
+	for i := range rep.Results {
+		r := &rep.Results[i]
+		data.Rows = append(data.Rows, emailRowOf(r))
+		text = append(text, r.Message())
+	}
+	var html bytes.Buffer
+	if err := emailTemplate.Execute(&html, data); err != nil {
+		return err
+	}
+	return n.send(rep.Title(), strings.Join(text, "\n"), html.String())
+}
+
+func (n *EmailNotifier) send(subject, text, html string) error {
+	msg, err := n.compose(subject, text, html)
+	if err != nil {
+		return err
+	}
+	if err := n.deliver(msg); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
+
+func (n *EmailNotifier) compose(subject, text, html string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if html == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	boundary := "crtwtch-" + hex.EncodeToString(b[:])
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct{ typ, body string }{{"text/plain", text}, {"text/html", html}} {
+		fmt.Fprintf(&buf, "--%s\r\nContent-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", boundary, part.typ)
+		if err := writeQuotedPrintable(&buf, part.body); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, s string) error {
+	w := quotedprintable.NewWriter(buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (n *EmailNotifier) deliver(msg []byte) error {
+	addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if n.Security == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: n.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	c, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if n.Security == "" || n.Security == "starttls" {
+		if err := c.StartTLS(&tls.Config{ServerName: n.Host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
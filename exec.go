@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterNotifier("exec", func(decode func(any) error) (Notifier, error) {
+		n := &ExecNotifier{Timeout: 30}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if len(n.Command) == 0 {
+			return nil, fmt.Errorf("exec: command is required")
+		}
+		return n, nil
+	})
+}
+
+// ExecNotifier runs a local command with the message on stdin and the
+// details in CRTWTCH_* environment variables. With PerSite the command runs
+// once per site the report lists instead of once per report: the alerts,
+// and in change mode and digests the sites that are OK too.
+type ExecNotifier struct {
+	Command []string `toml:"command"`
+	Timeout int      `toml:"timeout"` // seconds
+	PerSite bool     `toml:"per_site"`
+}
+
+func (n *ExecNotifier) Send(msg string, level slog.Level) error {
+	return n.run(msg, []string{"CRTWTCH_LEVEL=" + level.String()})
+}
+
+func (n *ExecNotifier) SendReport(rep *Report) error {
+	shown := rep.Shown()
+	if !n.PerSite {
+		sites := make([]string, 0, len(shown))
+		for _, r := range shown {
+			sites = append(sites, r.Site)
+		}
+		return n.run(rep.Text(), []string{
+			"CRTWTCH_GROUP=" + rep.Group,
+			"CRTWTCH_LEVEL=" + rep.Level().String(),
+			"CRTWTCH_TOTAL=" + strconv.Itoa(len(rep.Results)),
+			"CRTWTCH_ALERT_COUNT=" + strconv.Itoa(len(rep.Alerts())),
+			"CRTWTCH_SITES=" + strings.Join(sites, ","),
+		})
+	}
+	var errs []error
+	for i := range shown {
+		r := &shown[i]
+		env := append([]string{"CRTWTCH_GROUP=" + rep.Group}, resultEnv(r)...)
+		errs = append(errs, n.run(r.Message(), env))
+	}
+	return errors.Join(errs...)
+}
+
+// resultEnv describes one result as CRTWTCH_* environment variables.
+func resultEnv(r *Result) []string {
+	env := []string{
+		"CRTWTCH_SITE=" + r.Site,
+		"CRTWTCH_STATUS=" + r.Status.String(),
+		"CRTWTCH_LEVEL=" + r.Status.Level().String(),
+	}
+	if r.Err != nil {
+		return append(env, "CRTWTCH_ERROR="+r.Err.Error())
+	}
+	if r.Reason != "" {
+		env = append(env, "CRTWTCH_REASON="+r.Reason)
+	}
+	env = append(env,
+		"CRTWTCH_EXPIRE="+r.Expiry.Format(time.RFC3339),
+		"CRTWTCH_DAYS_LEFT="+strconv.Itoa(r.DaysLeft),
+		"CRTWTCH_ISSUER="+r.Issuer,
+		"CRTWTCH_SANS="+strings.Join(r.SANs, ","),
+		"CRTWTCH_CHAIN_LENGTH="+strconv.Itoa(r.ChainLength),
+	)
+	if r.TLSVersion != "" {
+		env = append(env, "CRTWTCH_TLS_VERSION="+r.TLSVersion)
+	}
+	if r.Handshake > 0 {
+		env = append(env,
+			"CRTWTCH_CONNECT_MS="+strconv.FormatInt(r.Connect.Milliseconds(), 10),
+			"CRTWTCH_HANDSHAKE_MS="+strconv.FormatInt(r.Handshake.Milliseconds(), 10),
+		)
+	}
+	return env
+}
+
+func (n *ExecNotifier) run(stdin string, env []string) error {
+	if err := runCommand(n.Command, time.Duration(n.Timeout)*time.Second, stdin, env); err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+// runCommand runs argv with stdin and extra environment variables, killing it
+// after timeout. Output is passed through to the log.
+func runCommand(argv []string, timeout time.Duration, stdin string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Env = append(os.Environ(), env...)
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		slog.Info("command output", "command", argv[0], "output", strings.TrimSpace(string(out)))
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: timed out after %s", argv[0], timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
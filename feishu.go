@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+func init() {
+	RegisterNotifier("feishu", func(decode func(any) error) (Notifier, error) {
+		n := &FeishuNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.WebhookURL == "" {
+			return nil, fmt.Errorf("feishu: webhook_url is required")
+		}
+		return n, nil
+	})
+}
+
+// FeishuNotifier posts interactive cards to a Feishu/Lark custom bot.
+// Secret is only needed when the bot has signature verification enabled.
+type FeishuNotifier struct {
+	WebhookURL string `toml:"webhook_url"`
+	Secret     string `toml:"secret"`
+}
+
+type feishuMessage struct {
+	Timestamp string     `json:"timestamp,omitempty"`
+	Sign      string     `json:"sign,omitempty"`
+	MsgType   string     `json:"msg_type"`
+	Card      feishuCard `json:"card"`
+}
+
+type feishuCard struct {
+	Header   feishuHeader `json:"header"`
+	Elements []any        `json:"elements"`
+}
+
+type feishuHeader struct {
+	Title    feishuText `json:"title"`
+	Template string     `json:"template"`
+}
+
+type feishuText struct {
+	Tag     string `json:"tag"`
+	Content string `json:"content"`
+}
+
+type feishuTable struct {
+	Tag      string              `json:"tag"`
+	PageSize int                 `json:"page_size"`
+	Columns  []feishuColumn      `json:"columns"`
+	Rows     []map[string]string `json:"rows"`
+}
+
+type feishuColumn struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	DataType    string `json:"data_type"`
+}
+
+type feishuResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// feishuSign computes the bot signature of timestamp (seconds). Unlike most
+// webhooks, Feishu uses the string-to-sign as the HMAC key over empty data.
+func feishuSign(timestamp int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(strconv.FormatInt(timestamp, 10)+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func feishuTemplate(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "red"
+	case level >= slog.LevelWarn:
+		return "orange"
+	}
+	return "green"
+}
+
+func (n *FeishuNotifier) Send(msg string, level slog.Level) error {
+	return n.post(feishuCard{
+		Header:   feishuHeader{Title: feishuText{Tag: "plain_text", Content: defaultMessages.labels.Heading}, Template: feishuTemplate(level)},
+		Elements: []any{map[string]any{"tag": "div", "text": feishuText{Tag: "plain_text", Content: msg}}},
+	})
+}
+
+func (n *FeishuNotifier) SendReport(rep *Report) error {
+	card := feishuCard{
+		Header: feishuHeader{Title: feishuText{Tag: "plain_text", Content: rep.Title()}, Template: feishuTemplate(rep.Level())},
+	}
+	shown := rep.Shown()
+	if len(shown) == 0 {
+		card.Elements = []any{map[string]any{"tag": "div", "text": feishuText{Tag: "plain_text", Content: rep.Title()}}}
+		return n.post(card)
+	}
+	l := rep.labels()
+	table := feishuTable{
+		Tag:      "table",
+		PageSize: 10,
+		Columns: []feishuColumn{
+			{Name: "site", DisplayName: l.Site, DataType: "text"},
+			{Name: "status", DisplayName: l.Status, DataType: "text"},
+			{Name: "expire", DisplayName: l.Expiry, DataType: "text"},
+			{Name: "days_left", DisplayName: l.DaysLeft, DataType: "lark_md"},
+		},
+	}
+	for _, r := range shown {
+		row := map[string]string{"site": r.Site, "status": l.Statuses[r.Status], "expire": "-", "days_left": "-"}
+		if r.Err == nil {
+			color := "orange"
+			switch r.Status {
+			case StatusOK:
+				color = "green"
+			case StatusExpired, StatusCritical:
+				color = "red"
+			}
+			row["expire"] = r.Expiry.Format("2006-01-02")
+			row["days_left"] = fmt.Sprintf("<font color='%s'>**%s**</font>", color, r.timeLeftCell())
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	card.Elements = []any{table}
+	return n.post(card)
+}
+
+func (n *FeishuNotifier) post(card feishuCard) error {
+	// wait before signing, the signature is only valid for an hour
+	throttle("feishu")
+	msg := feishuMessage{MsgType: "interactive", Card: card}
+	if n.Secret != "" {
+		ts := time.Now().Unix()
+		msg.Timestamp = strconv.FormatInt(ts, 10)
+		msg.Sign = feishuSign(ts, n.Secret)
+	}
+	body, err := postJSON(n.WebhookURL, msg)
+	if err != nil {
+		return fmt.Errorf("feishu: %w", err)
+	}
+	var resp feishuResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("feishu: bad response: %w", err)
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("feishu: code %d: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("gotify", func(decode func(any) error) (Notifier, error) {
+		n := &GotifyNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.Server == "" || n.Token == "" {
+			return nil, fmt.Errorf("gotify: server and token are required")
+		}
+		n.Server = strings.TrimRight(n.Server, "/")
+		return n, nil
+	})
+}
+
+// GotifyNotifier pushes messages to a Gotify server using an app token.
+type GotifyNotifier struct {
+	Server string `toml:"server"`
+	Token  string `toml:"token"`
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// gotifyPriority maps levels onto Gotify's 0-10 scale; clients only raise a
+// notification from 4 and sound from 8 by default.
+func gotifyPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 8
+	case level >= slog.LevelWarn:
+		return 5
+	}
+	return 2
+}
+
+func (n *GotifyNotifier) Send(msg string, level slog.Level) error {
+	title, body := splitMessage(msg)
+	header := map[string]string{"X-Gotify-Key": n.Token}
+	status, resp, err := sendJSON("POST", n.Server+"/message", header, gotifyMessage{Title: title, Message: body, Priority: gotifyPriority(level)})
+	if err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+	if status != 200 {
+		return fmt.Errorf("gotify: unexpected status code %d: %s", status, resp)
+	}
+	return nil
+}
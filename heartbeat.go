@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Heartbeat tells a dead man's switch, such as a Healthchecks.io check or an
+// Uptime Kuma push monitor, that the watcher is still running, so its own
+// silent failure is noticed. It can be set globally and per group; the
+// group's non-empty fields win.
+type Heartbeat struct {
+	URL     string `toml:"url"`      // requested after a pass whose notifications were all delivered
+	FailURL string `toml:"fail_url"` // requested instead after a pass with undelivered ones
+	Message bool   `toml:"message"`  // also send a short note through the group's channels
+}
+
+func (group *WatchGroup) heartbeat() Heartbeat {
+	hb := group.config.Heartbeat
+	if group.Heartbeat.URL != "" {
+		hb.URL = group.Heartbeat.URL
+	}
+	if group.Heartbeat.FailURL != "" {
+		hb.FailURL = group.Heartbeat.FailURL
+	}
+	hb.Message = hb.Message || group.Heartbeat.Message
+	return hb
+}
+
+// beat sends the group's heartbeat after a pass of rep that ended with err,
+// and returns err with that of the message, if any. A failed ping is only
+// logged: it is the missing ping that raises the alarm.
+func (group *WatchGroup) beat(rep *Report, err error) error {
+	hb := group.heartbeat()
+	if hb.Message && err == nil {
+		msg := fmt.Sprintf(rep.labels().Heartbeat, rep.Date.Format("2006-01-02 15:04"), group.Name, len(rep.Results))
+		err = group.notifyText(msg, slog.LevelInfo)
+	}
+	u := hb.URL
+	if err != nil {
+		u = hb.FailURL
+	}
+	if u == "" {
+		return err
+	}
+	status, _, perr := doHTTP("GET", u, nil, "")
+	if perr == nil && (status < 200 || status > 299) {
+		perr = fmt.Errorf("unexpected status code: %d", status)
+	}
+	if perr != nil {
+		slog.Error("heartbeat failed", "group", group.Name, "error", perr)
+	} else {
+		slog.Info("heartbeat sent", "group", group.Name, "ok", err == nil)
+	}
+	return err
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// historyStore records every check result in an SQLite database, for trend
+// queries and for looking back at when a certificate changed or a site
+// started failing. A nil store records nothing.
+type historyStore struct {
+	db   *sql.DB
+	path string
+	keep time.Duration // how long rows are kept, 0 for ever
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS checks (
+	time        INTEGER NOT NULL, -- unix seconds
+	grp         TEXT    NOT NULL,
+	site        TEXT    NOT NULL,
+	status      TEXT    NOT NULL,
+	days_left   INTEGER,
+	expiry      INTEGER,          -- unix seconds
+	serial      TEXT,
+	fingerprint TEXT,             -- SHA-256 of the leaf, hex
+	issuer      TEXT,
+	reason      TEXT,
+	error       TEXT,
+	handshake   INTEGER           -- milliseconds
+);
+CREATE INDEX IF NOT EXISTS checks_site ON checks (site, time);
+`
+
+// openHistory opens the history database at path, creating it if needed,
+// and drops the rows older than keepDays when that is set. An empty path
+// is no history.
+func openHistory(path string, keepDays int) (*historyStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &historyStore{db: db, path: path, keep: time.Duration(keepDays) * 24 * time.Hour}, nil
+}
+
+func (h *historyStore) close() {
+	if h != nil {
+		h.db.Close()
+	}
+}
+
+// record adds the results of rep. Failures are logged, a broken history
+// must not keep alerts from going out.
+func (h *historyStore) record(group string, rep *Report) {
+	if h == nil {
+		return
+	}
+	if err := h.insert(group, rep); err != nil {
+		slog.Error("failed to record history", "path", h.path, "error", err)
+	}
+}
+
+func (h *historyStore) insert(group string, rep *Report) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO checks (time, grp, site, status, days_left, expiry, serial, fingerprint, issuer, reason, error, handshake)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, r := range rep.Results {
+		var daysLeft, expiry, handshake, errText any
+		if !r.Expiry.IsZero() {
+			daysLeft, expiry = r.DaysLeft, r.Expiry.Unix()
+		}
+		if r.Handshake > 0 {
+			handshake = r.Handshake.Milliseconds()
+		}
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		if _, err := stmt.Exec(rep.Date.Unix(), group, r.Site, r.Status.String(), daysLeft, expiry,
+			r.Serial, r.leafHash, r.IssuerDN, r.Reason, errText, handshake); err != nil {
+			return err
+		}
+	}
+	if h.keep > 0 {
+		if _, err := tx.Exec(`DELETE FROM checks WHERE time < ?`, rep.Date.Add(-h.keep).Unix()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// historyCommand prints the last checks of a site, newest first.
+func historyCommand(config *Config, args []string) error {
+	if config.history == nil {
+		return fmt.Errorf("history needs history in the config")
+	}
+	limit := 20
+	switch len(args) {
+	case 2:
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("bad count %q", args[1])
+		}
+		limit = n
+	case 1:
+	default:
+		return fmt.Errorf("usage: crtwtch [-c config] history site [count]")
+	}
+	rows, err := config.history.db.Query(`SELECT time, grp, status, days_left, expiry, fingerprint, coalesce(nullif(error, ''), reason)
+		FROM checks WHERE site = ? ORDER BY time DESC LIMIT ?`, args[0], limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for rows.Next() {
+		var at int64
+		var group, status string
+		var daysLeft, expiry sql.NullInt64
+		var fingerprint, detail sql.NullString
+		if err := rows.Scan(&at, &group, &status, &daysLeft, &expiry, &fingerprint, &detail); err != nil {
+			return err
+		}
+		left, until := "-", "-"
+		if expiry.Valid {
+			left = strconv.FormatInt(daysLeft.Int64, 10)
+			until = time.Unix(expiry.Int64, 0).In(config.loc).Format(time.DateOnly)
+		}
+		fp := fingerprint.String
+		if len(fp) > 16 {
+			fp = fp[:16]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", time.Unix(at, 0).In(config.loc).Format(time.DateTime), group, status, left, until, fp, detail.String)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Hooks are commands run when a site's certificate needs attention or was
+// renewed, such as to start certbot or open a ticket. They get the result
+// in the CRTWTCH_* environment variables of the exec notifier, CRTWTCH_HOOK
+// naming the hook, and its message on stdin. They can be set per group and
+// per site; the most specific non-empty value of each field wins.
+type Hooks struct {
+	OnWarning   []string `toml:"on_warning"` // also for critical
+	OnExpired   []string `toml:"on_expired"`
+	OnRenewed   []string `toml:"on_renewed"`
+	HookTimeout int      `toml:"hook_timeout"` // seconds, default 300
+}
+
+const defaultHookTimeout = 300 * time.Second
+
+// hooks resolves the hooks of the site.
+func (group *WatchGroup) hooks(site *Site) Hooks {
+	h := group.Hooks
+	if len(site.OnWarning) > 0 {
+		h.OnWarning = site.OnWarning
+	}
+	if len(site.OnExpired) > 0 {
+		h.OnExpired = site.OnExpired
+	}
+	if len(site.OnRenewed) > 0 {
+		h.OnRenewed = site.OnRenewed
+	}
+	if site.HookTimeout > 0 {
+		h.HookTimeout = site.HookTimeout
+	}
+	return h
+}
+
+// runHooks runs the hooks of the sites of rep that turned warning, critical
+// or expired since their last recorded status, and of the renewed ones.
+// Sites muted by a maintenance window or snooze are left alone. It has to
+// run before the report is recorded; without a state file that only lasts
+// as long as the process, so cron runs call the hooks every time. Failed
+// hooks are logged.
+func (group *WatchGroup) runHooks(rep *Report, renewed []Result) {
+	state := group.config.state
+	state.mu.Lock()
+	prev := make(map[string]string, len(rep.Results))
+	for _, r := range rep.Results {
+		if st := state.Sites[stateKey(group.Name, r.Site)]; st != nil {
+			prev[r.Site] = st.Status
+		}
+	}
+	state.mu.Unlock()
+	for i := range rep.Results {
+		r := &rep.Results[i]
+		if prev[r.Site] == r.Status.String() {
+			continue
+		}
+		site := group.site(r.addr())
+		if _, ok := group.muted(site, rep.Date); ok {
+			continue
+		}
+		h := group.hooks(site)
+		switch {
+		case r.Status.expiring():
+			group.runHook("on_warning", h.OnWarning, h, r, r.Message())
+		case r.Status == StatusExpired:
+			group.runHook("on_expired", h.OnExpired, h, r, r.Message())
+		}
+	}
+	for i := range renewed {
+		r := &renewed[i]
+		h := group.hooks(group.site(r.addr()))
+		group.runHook("on_renewed", h.OnRenewed, h, r, r.messages.renewedLine(r))
+	}
+}
+
+func (group *WatchGroup) runHook(name string, argv []string, h Hooks, r *Result, stdin string) {
+	if len(argv) == 0 {
+		return
+	}
+	timeout := defaultHookTimeout
+	if h.HookTimeout > 0 {
+		timeout = time.Duration(h.HookTimeout) * time.Second
+	}
+	env := append([]string{"CRTWTCH_GROUP=" + group.Name, "CRTWTCH_HOOK=" + name}, resultEnv(r)...)
+	if !r.PrevExpiry.IsZero() {
+		env = append(env, "CRTWTCH_PREV_EXPIRE="+r.PrevExpiry.Format(time.RFC3339))
+	}
+	slog.Info("running hook", "hook", name, "site", r.Site, "command", argv[0])
+	if err := runCommand(argv, timeout, stdin, env); err != nil {
+		slog.Error("hook failed:", "hook", name, "site", r.Site, "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const minRSABits = 2048
+
+// checksHygiene reports whether the group checks its chains for weak keys
+// and signatures: its own hygiene setting if it has one, else the global one.
+func (group *WatchGroup) checksHygiene() bool {
+	if group.Hygiene != nil {
+		return *group.Hygiene
+	}
+	return group.config.Hygiene
+}
+
+// weakSignatures are the signature algorithms browsers no longer accept.
+var weakSignatures = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.DSAWithSHA256: true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// checkHygiene marks r when a certificate of chain has an RSA key under
+// 2048 bits, a DSA key, an elliptic curve under P-256, or is signed with
+// MD5, SHA-1 or DSA. Self-signed roots are trusted for their key rather than
+// their signature, so only their key is looked at. Weak crypto is less
+// pressing than the other problems, so it only replaces an OK status.
+func (group *WatchGroup) checkHygiene(r *Result, chain []*x509.Certificate) {
+	var findings []string
+	for i, cert := range chain {
+		var weak []string
+		switch key := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if bits := key.N.BitLen(); bits < minRSABits {
+				weak = append(weak, fmt.Sprintf("%d-bit RSA key", bits))
+			}
+		case *ecdsa.PublicKey:
+			if bits := key.Curve.Params().BitSize; bits < 256 {
+				weak = append(weak, fmt.Sprintf("%d-bit EC key", bits))
+			}
+		default:
+			if cert.PublicKeyAlgorithm == x509.DSA {
+				weak = append(weak, "DSA key")
+			}
+		}
+		selfSigned := bytes.Equal(cert.RawIssuer, cert.RawSubject)
+		if weakSignatures[cert.SignatureAlgorithm] && !selfSigned {
+			weak = append(weak, cert.SignatureAlgorithm.String()+" signature")
+		}
+		if len(weak) == 0 {
+			continue
+		}
+		name := "leaf"
+		if i > 0 {
+			name = subjectName(cert)
+		}
+		findings = append(findings, name+": "+strings.Join(weak, ", "))
+	}
+	if len(findings) == 0 {
+		return
+	}
+	reason := strings.Join(findings, "; ")
+	slog.Warn("weak crypto in the certificate chain", "site", r.Site, "findings", reason)
+	if r.Status == StatusOK {
+		r.Status, r.Reason = StatusHygiene, reason
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// labelSet is the fixed wording notifiers use around the templated lines,
+// such as table headers and field names.
+type labelSet struct {
+	Heading  string
+	Site     string
+	Status   string
+	Expiry   string
+	DaysLeft string
+	Issuer   string
+	Error    string
+	Statuses [numStatus]string // indexed by Status
+	// HoursLeft stands for DaysLeft when the time left is counted in hours.
+	HoursLeft string
+	// SMSExpired is a format string taking the group, count and site list.
+	SMSExpired string
+	// WeeklyTitle is a format string taking the date and group.
+	WeeklyTitle  string
+	Renewed      string
+	ExpiringSoon string
+	Flaky        string
+	None         string
+	// Heartbeat is a format string taking the time, group and site count.
+	Heartbeat string
+}
+
+var zhTemplates = MessageTemplates{
+	Title:            `{{if .Alerts}}🚨 [{{date .Date}}] 组 {{.Group}} 的证书监控发现 {{len .Alerts}} 个问题:{{else}}✅ [{{date .Date}}] 组 {{.Group}} 的证书监控正常，共 {{len .Results}} 个{{end}}`,
+	OK:               `✅ 证书正常: {{.Site}} 还有 {{if .InHours}}{{.HoursLeft}} 小时{{else}}{{.DaysLeft}} 天{{end}} (到期日: {{date .Expiry}})`,
+	Warning:          `⚠️ 证书即将过期: {{.Site}}{{if .Intermediate}} 的中间证书 {{.Subject}}{{end}} 还有 {{if .InHours}}{{.HoursLeft}} 小时{{else}}{{.DaysLeft}} 天{{end}} (到期日: {{date .Expiry}})`,
+	Critical:         `🔥 证书即将过期 (紧急): {{.Site}}{{if .Intermediate}} 的中间证书 {{.Subject}}{{end}} 只剩 {{if .InHours}}{{.HoursLeft}} 小时{{else}}{{.DaysLeft}} 天{{end}} (到期日: {{date .Expiry}})`,
+	Expired:          `❗ 证书已过期: {{.Site}}{{if .Intermediate}} 的中间证书 {{.Subject}}{{end}} (到期日: {{date .Expiry}})`,
+	Failed:           `❗ 检测失败: {{.Site}}{{if .TimedOut}} (超时){{end}}{{if gt .Attempts 1}} (已尝试 {{.Attempts}} 次){{end}}`,
+	Skipped:          `⏭️ 未检测: {{.Site}} (超出本轮时限)`,
+	Untrusted:        `🔒 证书链校验失败: {{.Site}} ({{.Reason}})`,
+	Mismatch:         `🔀 证书与域名不匹配: {{.Site}} ({{.Reason}})`,
+	Revoked:          `🚫 证书已吊销: {{.Site}} ({{.Reason}})`,
+	OCSPFailed:       `❔ OCSP 查询失败: {{.Site}} ({{.Reason}})`,
+	Staple:           `📎 OCSP 装订异常: {{.Site}} ({{.Reason}})`,
+	CT:               `🔍 证书透明度不合规: {{.Site}} ({{.Reason}})`,
+	UnexpectedIssuer: `🕵️ 发现非预期 CA 签发的证书: {{.Site}} ({{.Reason}}, 到期日: {{date .Expiry}})`,
+	PinMismatch:      `📌 证书与固定的指纹不符: {{.Site}} ({{.Reason}})`,
+	Hygiene:          `🧹 证书使用了弱算法: {{.Site}} ({{.Reason}})`,
+	TLSPolicy:        `🔐 TLS 配置不合规: {{.Site}} ({{.Reason}})`,
+	SelfSigned:       `🪞 自签名证书: {{.Site}} ({{.Reason}})`,
+	Validity:         `📆 有效期异常: {{.Site}} ({{.Reason}})`,
+	DANE:             `🔏 DANE 校验失败: {{.Site}} ({{.Reason}})`,
+	RenewalDue:       `🔁 CA 建议续期: {{.Site}} ({{.Reason}})`,
+	Drift:            `📋 证书与台账不符: {{.Site}} ({{.Reason}})`,
+	Duplicate:        `👯 密钥被多台主机共用: {{.Site}} ({{.Reason}})`,
+	Slow:             `🐢 TLS 握手过慢: {{.Site}} ({{.Reason}})`,
+	Details:          `    签发者: {{.Issuer}} | 域名: {{names .SANs}} |{{with .TLSVersion}} {{.}} |{{end}} 证书链: {{.ChainLength}} 张`,
+	Renewed:          `🔄 证书已续期: {{.Site}} 现在有效期至 {{date .Expiry}} (原到期日: {{date .PrevExpiry}})`,
+	IssuerChanged:    `🏷️ 证书签发者变更: {{.Site}} 由 {{.PrevIssuer}} 变为 {{.IssuerDN}}`,
+}
+
+var zhLabels = labelSet{
+	Heading:    "证书监控",
+	Site:       "站点",
+	Status:     "状态",
+	Expiry:     "到期日",
+	DaysLeft:   "剩余天数",
+	HoursLeft:  "剩余小时",
+	Issuer:     "签发者",
+	Error:      "错误",
+	Statuses:   [numStatus]string{"正常", "即将过期", "紧急", "已过期", "检测失败", "未检测", "不受信任", "域名不匹配", "已吊销", "OCSP 查询失败", "OCSP 装订异常", "透明度不合规", "非预期签发", "指纹不符", "弱算法", "TLS 不合规", "自签名", "有效期异常", "DANE 不匹配", "应续期", "台账不符", "密钥共用", "握手过慢"},
+	SMSExpired: "[crtwtch] 组 %s 有 %d 个证书已过期: %s",
+
+	WeeklyTitle:  "📅 [%s] 组 %s 的证书周报",
+	Renewed:      "本周已续期",
+	ExpiringSoon: "7 天内到期",
+	Flaky:        "检测不稳定 (失败/检测次数)",
+	None:         "无",
+
+	Heartbeat: "💓 [%s] 组 %s 已完成检查，共 %d 个站点",
+}
+
+var enTemplates = MessageTemplates{
+	Title:            `{{if .Alerts}}🚨 [{{date .Date}}] Group {{.Group}}: {{len .Alerts}} certificate problem(s):{{else}}✅ [{{date .Date}}] Group {{.Group}}: all {{len .Results}} certificate(s) OK{{end}}`,
+	OK:               `✅ OK: {{.Site}} expires in {{if .InHours}}{{.HoursLeft}} hours{{else}}{{.DaysLeft}} days{{end}} ({{date .Expiry}})`,
+	Warning:          `⚠️ Expiring soon: {{.Site}}{{if .Intermediate}} intermediate {{.Subject}}{{end}} expires in {{if .InHours}}{{.HoursLeft}} hours{{else}}{{.DaysLeft}} days{{end}} ({{date .Expiry}})`,
+	Critical:         `🔥 Expiring very soon: {{.Site}}{{if .Intermediate}} intermediate {{.Subject}}{{end}} expires in {{if .InHours}}{{.HoursLeft}} hours{{else}}{{.DaysLeft}} days{{end}} ({{date .Expiry}})`,
+	Expired:          `❗ Expired: {{.Site}}{{if .Intermediate}} intermediate {{.Subject}}{{end}} ({{date .Expiry}})`,
+	Failed:           `❗ Check failed: {{.Site}}{{if .TimedOut}} (timed out){{end}}{{if gt .Attempts 1}} after {{.Attempts}} attempts{{end}}`,
+	Skipped:          `⏭️ Skipped: {{.Site}} (run deadline reached)`,
+	Untrusted:        `🔒 Untrusted: {{.Site}} ({{.Reason}})`,
+	Mismatch:         `🔀 Name mismatch: {{.Site}} ({{.Reason}})`,
+	Revoked:          `🚫 Revoked: {{.Site}} ({{.Reason}})`,
+	OCSPFailed:       `❔ OCSP check failed: {{.Site}} ({{.Reason}})`,
+	Staple:           `📎 Bad OCSP staple: {{.Site}} ({{.Reason}})`,
+	CT:               `🔍 Certificate Transparency: {{.Site}} ({{.Reason}})`,
+	UnexpectedIssuer: `🕵️ Unexpected certificate: {{.Site}} ({{.Reason}}, expires {{date .Expiry}})`,
+	PinMismatch:      `📌 Pin mismatch: {{.Site}} ({{.Reason}})`,
+	Hygiene:          `🧹 Weak crypto: {{.Site}} ({{.Reason}})`,
+	TLSPolicy:        `🔐 TLS policy: {{.Site}} ({{.Reason}})`,
+	SelfSigned:       `🪞 Self-signed: {{.Site}} ({{.Reason}})`,
+	Validity:         `📆 Bad validity: {{.Site}} ({{.Reason}})`,
+	DANE:             `🔏 DANE mismatch: {{.Site}} ({{.Reason}})`,
+	RenewalDue:       `🔁 Renewal due: {{.Site}} ({{.Reason}})`,
+	Drift:            `📋 Inventory drift: {{.Site}} ({{.Reason}})`,
+	Duplicate:        `👯 Shared key: {{.Site}} ({{.Reason}})`,
+	Slow:             `🐢 Slow handshake: {{.Site}} ({{.Reason}})`,
+	Details:          `    Issuer: {{.Issuer}} | Names: {{names .SANs}} |{{with .TLSVersion}} {{.}} |{{end}} Chain: {{.ChainLength}}`,
+	Renewed:          `🔄 Renewed: {{.Site}} is now valid until {{date .Expiry}} (was {{date .PrevExpiry}})`,
+	IssuerChanged:    `🏷️ Issuer changed: {{.Site}} is now issued by {{.IssuerDN}} (was {{.PrevIssuer}})`,
+}
+
+var enLabels = labelSet{
+	Heading:    "Certificate watch",
+	Site:       "Site",
+	Status:     "Status",
+	Expiry:     "Expires",
+	DaysLeft:   "Days left",
+	HoursLeft:  "Hours left",
+	Issuer:     "Issuer",
+	Error:      "Error",
+	Statuses:   [numStatus]string{"OK", "Expiring", "Critical", "Expired", "Failed", "Skipped", "Untrusted", "Name mismatch", "Revoked", "OCSP failed", "Bad staple", "CT missing", "Unexpected issuer", "Pin mismatch", "Weak crypto", "TLS policy", "Self-signed", "Bad validity", "DANE mismatch", "Renewal due", "Drift", "Shared key", "Slow"},
+	SMSExpired: "[crtwtch] %s: %d certificate(s) expired: %s",
+
+	WeeklyTitle:  "📅 [%s] Weekly certificate report for group %s",
+	Renewed:      "Renewed this week",
+	ExpiringSoon: "Expiring within 7 days",
+	Flaky:        "Flaky checks (failures/checks)",
+	None:         "none",
+
+	Heartbeat: "💓 [%s] Group %s checked, %d site(s)",
+}
+
+// catalogs are the built-in message sets by language code; custom
+// templates of a group are layered on top of the selected one.
+var catalogs = map[string]*messageSet{
+	"zh": mustMessageSet(zhTemplates, &zhLabels),
+	"en": mustMessageSet(enTemplates, &enLabels),
+}
+
+const defaultLang = "zh"
+
+func catalog(lang string) (*messageSet, error) {
+	if lang == "" {
+		lang = defaultLang
+	}
+	// accept region tags such as zh-CN or en_US
+	base, _, _ := strings.Cut(strings.ReplaceAll(strings.ToLower(lang), "_", "-"), "-")
+	m, ok := catalogs[base]
+	if !ok {
+		langs := make([]string, 0, len(catalogs))
+		for l := range catalogs {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		return nil, fmt.Errorf("unsupported lang %q (available: %s)", lang, strings.Join(langs, ", "))
+	}
+	return m, nil
+}
+
+func (m *messageSet) labelSet() *labelSet {
+	if m == nil {
+		m = defaultMessages
+	}
+	return m.labels
+}
+
+// labels returns the fixed wording for the report's language.
+func (rep *Report) labels() *labelSet { return rep.messages.labelSet() }
+
+// labels returns the fixed wording for the result's language.
+func (r *Result) labels() *labelSet { return r.messages.labelSet() }
+
+// timeLeft returns the label and count of the time left as messages give
+// it: in hours when InHours, else in days.
+func (r *Result) timeLeft() (label string, n int) {
+	l := r.labels()
+	if r.InHours() {
+		return l.HoursLeft, r.HoursLeft()
+	}
+	return l.DaysLeft, r.DaysLeft
+}
+
+// timeLeftCell renders the time left for a days left column, naming the
+// unit when it is hours.
+func (r *Result) timeLeftCell() string {
+	label, n := r.timeLeft()
+	if r.InHours() {
+		return fmt.Sprintf("%d (%s)", n, label)
+	}
+	return strconv.Itoa(n)
+}
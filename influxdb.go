@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InfluxDB receives a point per result of every pass in the InfluxDB line
+// protocol, appended to File, written to an InfluxDB 2 server at URL, or
+// both, for graphing certificate lifetimes in Grafana.
+type InfluxDB struct {
+	File   string `toml:"file"`
+	URL    string `toml:"url"` // such as "http://influxdb:8086"
+	Org    string `toml:"org"`
+	Bucket string `toml:"bucket"`
+	Token  string `toml:"token"`
+}
+
+// influxMeasurement names the points written.
+const influxMeasurement = "crtwtch_check"
+
+func (db *InfluxDB) load() error {
+	if db.File == "" && db.URL == "" {
+		return errors.New("influxdb: file or url is required")
+	}
+	if db.URL != "" && (db.Org == "" || db.Bucket == "") {
+		return errors.New("influxdb: org and bucket are required with url")
+	}
+	return nil
+}
+
+// write sends the results of rep. Failures are logged, like failed
+// pushes to a Pushgateway.
+func (db *InfluxDB) write(group string, rep *Report) {
+	if db == nil {
+		return
+	}
+	var b strings.Builder
+	for i := range rep.Results {
+		influxLine(&b, group, &rep.Results[i], rep.Date.Unix())
+	}
+	if db.File != "" {
+		if err := appendFile(db.File, b.String()); err != nil {
+			slog.Error("failed to write InfluxDB points", "group", group, "file", db.File, "error", err)
+		}
+	}
+	if db.URL != "" {
+		if err := db.post(b.String()); err != nil {
+			slog.Error("failed to write InfluxDB points", "group", group, "url", db.URL, "error", err)
+		}
+	}
+}
+
+func (db *InfluxDB) post(lines string) error {
+	q := url.Values{"org": {db.Org}, "bucket": {db.Bucket}, "precision": {"s"}}
+	header := map[string]string{"Content-Type": "text/plain; charset=utf-8"}
+	if db.Token != "" {
+		header["Authorization"] = "Token " + db.Token
+	}
+	status, body, err := doHTTP("POST", strings.TrimSuffix(db.URL, "/")+"/api/v2/write?"+q.Encode(), header, lines)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("unexpected status code: %d: %s", status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func appendFile(path, data string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// influxLine writes the point of r at the Unix time ts, tagged with its
+// group and site.
+func influxLine(b *strings.Builder, group string, r *Result, ts int64) {
+	b.WriteString(influxMeasurement)
+	b.WriteString(",group=" + influxTag(group) + ",site=" + influxTag(r.Site) + " ")
+	b.WriteString("status=" + influxString(r.Status.String()))
+	success := r.Err == nil && r.Status != StatusSkipped
+	b.WriteString(",success=" + strconv.FormatBool(success))
+	b.WriteString(",attempts=" + strconv.Itoa(r.Attempts) + "i")
+	if r.Duration > 0 {
+		b.WriteString(",duration=" + strconv.FormatFloat(r.Duration.Seconds(), 'f', -1, 64))
+	}
+	if !r.Expiry.IsZero() {
+		b.WriteString(",days_left=" + strconv.Itoa(r.DaysLeft) + "i")
+		b.WriteString(",not_after=" + strconv.FormatInt(r.Expiry.Unix(), 10) + "i")
+		b.WriteString(",issuer=" + influxString(r.IssuerDN))
+		b.WriteString(",serial=" + influxString(r.Serial))
+	}
+	if r.Handshake > 0 {
+		b.WriteString(",handshake=" + strconv.FormatFloat(r.Handshake.Seconds(), 'f', -1, 64))
+	}
+	if r.Err != nil {
+		b.WriteString(",error=" + influxString(r.Err.Error()))
+	}
+	b.WriteString(" " + strconv.FormatInt(ts, 10) + "\n")
+}
+
+// influxTag escapes a tag value.
+func influxTag(v string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`).Replace(v)
+}
+
+// influxString quotes a string field value.
+func influxString(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
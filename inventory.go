@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// Expect is what a site's leaf certificate is declared to be in the
+// inventory. Every field that is set has to match, so a certificate that
+// drifts from it, renewed by another CA or for other names, or swapped
+// outright, is alerted.
+type Expect struct {
+	// Issuer is the CN, organization or full DN of the issuer.
+	Issuer string `toml:"issuer"`
+	// SANs are the DNS names and IP addresses the certificate is for, all
+	// of them in any order.
+	SANs []string `toml:"sans"`
+	// Serial and Fingerprint, the SHA-256 of the certificate, are hex with
+	// or without colons, and pin one certificate.
+	Serial      string `toml:"serial"`
+	Fingerprint string `toml:"fingerprint"`
+	// KeyType is "rsa", "ecdsa" or "ed25519", optionally with the size
+	// as in "rsa-2048" and "ecdsa-p256".
+	KeyType string `toml:"key_type"`
+
+	serial      *big.Int
+	fingerprint []byte
+}
+
+func (e *Expect) load() error {
+	if e.Serial != "" {
+		var ok bool
+		if e.serial, ok = new(big.Int).SetString(strings.ReplaceAll(e.Serial, ":", ""), 16); !ok {
+			return fmt.Errorf("expect: bad serial %q", e.Serial)
+		}
+	}
+	if e.Fingerprint != "" {
+		hash, err := hex.DecodeString(strings.ReplaceAll(e.Fingerprint, ":", ""))
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("expect: bad fingerprint %q, want a SHA-256 fingerprint", e.Fingerprint)
+		}
+		e.fingerprint = hash
+	}
+	if e.KeyType != "" {
+		kind, _, _ := strings.Cut(strings.ToLower(e.KeyType), "-")
+		if kind != "rsa" && kind != "ecdsa" && kind != "ed25519" {
+			return fmt.Errorf("expect: unknown key_type %q, want rsa, ecdsa or ed25519", e.KeyType)
+		}
+	}
+	return nil
+}
+
+// drift lists how leaf differs from e.
+func (e *Expect) drift(leaf *x509.Certificate) []string {
+	var diffs []string
+	if e.Issuer != "" && !issuedBy(leaf, e.Issuer) {
+		diffs = append(diffs, "issuer "+leaf.Issuer.String())
+	}
+	if len(e.SANs) > 0 {
+		want, got := normalizeNames(e.SANs), normalizeNames(certNames(leaf))
+		if !slices.Equal(want, got) {
+			diffs = append(diffs, "names "+strings.Join(got, ", "))
+		}
+	}
+	if e.serial != nil && e.serial.Cmp(leaf.SerialNumber) != 0 {
+		diffs = append(diffs, "serial "+leaf.SerialNumber.Text(16))
+	}
+	if e.fingerprint != nil {
+		if sum := sha256.Sum256(leaf.Raw); !slices.Equal(sum[:], e.fingerprint) {
+			diffs = append(diffs, "fingerprint "+hex.EncodeToString(sum[:]))
+		}
+	}
+	if got := keyType(leaf); e.KeyType != "" && !keyTypeMatches(e.KeyType, got) {
+		diffs = append(diffs, "key "+got)
+	}
+	return diffs
+}
+
+// checkDrift marks r when the leaf of chain differs from what the
+// inventory expects of the site; the reason lists what was found instead.
+func checkDrift(r *Result, site *Site, chain []*x509.Certificate) {
+	if site.Expect == nil {
+		return
+	}
+	diffs := site.Expect.drift(chain[0])
+	if len(diffs) == 0 {
+		return
+	}
+	r.Status, r.Reason = StatusDrift, "drifted from the inventory: "+strings.Join(diffs, "; ")
+	slog.Warn("certificate drifted from the inventory", "site", r.Site, "diffs", diffs)
+}
+
+func issuedBy(cert *x509.Certificate, issuer string) bool {
+	return strings.EqualFold(issuer, cert.Issuer.CommonName) ||
+		strings.EqualFold(issuer, cert.Issuer.String()) ||
+		slices.ContainsFunc(cert.Issuer.Organization, func(org string) bool { return strings.EqualFold(issuer, org) })
+}
+
+// certNames returns the DNS names and IP addresses of cert.
+func certNames(cert *x509.Certificate) []string {
+	names := slices.Clone(cert.DNSNames)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return names
+}
+
+func normalizeNames(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = strings.ToLower(strings.TrimSuffix(n, "."))
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// keyType names the public key of cert as key_type does, with its size.
+func keyType(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("rsa-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ecdsa-" + strings.ToLower(strings.ReplaceAll(key.Curve.Params().Name, "-", ""))
+	case ed25519.PublicKey:
+		return "ed25519"
+	}
+	return strings.ToLower(cert.PublicKeyAlgorithm.String())
+}
+
+// keyTypeMatches reports whether the key type got is the expected one,
+// which matches any size when it gives none.
+func keyTypeMatches(want, got string) bool {
+	want = strings.ToLower(want)
+	return want == got || !strings.Contains(want, "-") && strings.HasPrefix(got, want+"-")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// readJKS returns the certificates of a Java keystore: the leaf of every key
+// entry and every trusted certificate entry. Certificates are stored in the
+// clear, so the password is only needed to check the keystore's digest.
+func readJKS(data []byte, password string) ([]*x509.Certificate, error) {
+	if len(data) < 12+sha1.Size {
+		return nil, fmt.Errorf("jks: file too short")
+	}
+	if password != "" {
+		body := data[:len(data)-sha1.Size]
+		h := sha1.New()
+		for _, c := range utf16.Encode([]rune(password)) {
+			h.Write([]byte{byte(c >> 8), byte(c)})
+		}
+		h.Write([]byte("Mighty Aphrodite"))
+		h.Write(body)
+		if !bytes.Equal(h.Sum(nil), data[len(body):]) {
+			return nil, fmt.Errorf("jks: wrong password or corrupt keystore")
+		}
+	}
+	r := &jksReader{data: data}
+	if magic := r.uint32(); magic != 0xfeedfeed {
+		return nil, fmt.Errorf("jks: not a Java keystore (magic %#x); PKCS#12 keystores need a .p12 name", magic)
+	}
+	version := r.uint32()
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("jks: unsupported version %d", version)
+	}
+	var certs []*x509.Certificate
+	readCert := func(keep bool) {
+		if version == 2 {
+			r.utf() // certificate type, "X.509"
+		}
+		der := r.bytes()
+		if r.err != nil || !keep {
+			return
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			r.err = err
+			return
+		}
+		certs = append(certs, cert)
+	}
+	for n := r.count(4 + 2 + 8); n > 0 && r.err == nil; n-- {
+		tag := r.uint32()
+		r.utf()   // alias
+		r.skip(8) // creation time
+		switch tag {
+		case 1: // private key, with its chain
+			r.bytes()
+			for i, n := 0, r.count(4); i < n && r.err == nil; i++ {
+				readCert(i == 0) // only the leaf
+			}
+		case 2: // trusted certificate
+			readCert(true)
+		default:
+			return nil, fmt.Errorf("jks: unsupported entry type %d", tag)
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("jks: %w", r.err)
+	}
+	if len(certs) == 0 {
+		return nil, errNoCerts
+	}
+	return certs, nil
+}
+
+// jksReader reads the big endian fields of a keystore. The first error
+// sticks and makes further reads return zero values.
+type jksReader struct {
+	data []byte
+	err  error
+}
+
+var errJKSShort = errors.New("unexpected end of keystore")
+
+func (r *jksReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = errJKSShort
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *jksReader) skip(n int) { r.next(n) }
+
+func (r *jksReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// count reads the number of items that follow, each taking at least size
+// bytes, so a corrupt count fails here rather than after billions of reads.
+func (r *jksReader) count(size int) int {
+	n := r.uint32()
+	if r.err == nil && uint64(n) > uint64(len(r.data)/size) {
+		r.err = errJKSShort
+		return 0
+	}
+	return int(n)
+}
+
+// utf skips a length prefixed modified UTF-8 string.
+func (r *jksReader) utf() {
+	if b := r.next(2); b != nil {
+		r.skip(int(binary.BigEndian.Uint16(b)))
+	}
+}
+
+func (r *jksReader) bytes() []byte { return r.next(int(r.uint32())) }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestReadJKSCorruptCount(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		chain uint32
+		extra int
+	}{
+		{"huge chain", 0xffffffff, 32},
+		{"truncated chain", 3, 8},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var data []byte
+			data = binary.BigEndian.AppendUint32(data, 0xfeedfeed)
+			data = binary.BigEndian.AppendUint32(data, 2)
+			data = binary.BigEndian.AppendUint32(data, 1) // entries
+			data = binary.BigEndian.AppendUint32(data, 1) // private key entry
+			data = binary.BigEndian.AppendUint16(data, 0) // alias
+			data = append(data, make([]byte, 8)...)       // creation time
+			data = binary.BigEndian.AppendUint32(data, 0) // key
+			data = binary.BigEndian.AppendUint32(data, tt.chain)
+			data = append(data, make([]byte, tt.extra)...)
+			if _, err := readJKS(data, ""); !errors.Is(err, errJKSShort) {
+				t.Errorf("readJKS = %v, want %v", err, errJKSShort)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kubernetes has a group also check the kubernetes.io/tls Secrets of a
+// cluster. Each secret is reported as the site "k8s://namespace/name".
+// Without a kubeconfig, the in-cluster service account is used when running
+// in a pod, and $KUBECONFIG or ~/.kube/config otherwise.
+type Kubernetes struct {
+	Kubeconfig string   `toml:"kubeconfig"`
+	Context    string   `toml:"context"`    // default the kubeconfig's current context
+	Namespaces []string `toml:"namespaces"` // default all namespaces
+}
+
+// kubeClient talks to one API server.
+type kubeClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+func (k *Kubernetes) client(timeout time.Duration) (*kubeClient, error) {
+	tlsConfig := &tls.Config{}
+	c := &kubeClient{http: &http.Client{Timeout: timeout, Transport: &http.Transport{TLSClientConfig: tlsConfig}}}
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if k.Kubeconfig == "" && host != "" {
+		token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+		if err != nil {
+			return nil, err
+		}
+		ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+		if err != nil {
+			return nil, err
+		}
+		c.server = "https://" + net.JoinHostPort(host, port)
+		c.token = strings.TrimSpace(string(token))
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+		return c, nil
+	}
+	path := k.Kubeconfig
+	if path == "" {
+		path, _, _ = strings.Cut(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".kube", "config")
+	}
+	if err := c.fromKubeconfig(path, k.Context, tlsConfig); err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// kubeconfig is the part of a kubeconfig file needed to reach a cluster.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Clusters []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+			Exec                  any    `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// fromKubeconfig sets c up for the named context of the kubeconfig at path,
+// or its current context. Exec and auth provider plugins are not supported.
+func (c *kubeClient) fromKubeconfig(path, name string, tlsConfig *tls.Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var kc kubeconfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return err
+	}
+	if name == "" {
+		name = kc.CurrentContext
+	}
+	dir := filepath.Dir(path)
+	// inline data wins over files, whose paths are relative to the kubeconfig
+	read := func(inline, file string) ([]byte, error) {
+		if inline != "" {
+			return base64.StdEncoding.DecodeString(inline)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		return os.ReadFile(file)
+	}
+	var clusterName, userName string
+	found := false
+	for _, kctx := range kc.Contexts {
+		if kctx.Name == name {
+			clusterName, userName, found = kctx.Context.Cluster, kctx.Context.User, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("context %q not found", name)
+	}
+	found = false
+	for _, cl := range kc.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		found = true
+		c.server = strings.TrimSuffix(cl.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+		tlsConfig.ServerName = cl.Cluster.TLSServerName
+		ca, err := read(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority)
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", clusterName, err)
+		}
+		if ca != nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return fmt.Errorf("cluster %s: no certificate in the certificate authority", clusterName)
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("cluster %q not found", clusterName)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		if u.User.Exec != nil {
+			return fmt.Errorf("user %s: exec credential plugins are not supported, use a token or client certificate", userName)
+		}
+		c.token = u.User.Token
+		if u.User.TokenFile != "" {
+			token, err := read("", u.User.TokenFile)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", userName, err)
+			}
+			c.token = strings.TrimSpace(string(token))
+		}
+		certPEM, err := read(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", userName, err)
+		}
+		keyPEM, err := read(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", userName, err)
+		}
+		if certPEM != nil {
+			pair, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", userName, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	return nil
+}
+
+type kubeSecret struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Data map[string][]byte `json:"data"` // base64 in JSON
+}
+
+// tlsSecrets lists the kubernetes.io/tls secrets of namespace, or of all
+// namespaces when it is empty, a page at a time.
+func (c *kubeClient) tlsSecrets(ctx context.Context, namespace string) ([]kubeSecret, error) {
+	path := "/api/v1/secrets"
+	if namespace != "" {
+		path = "/api/v1/namespaces/" + url.PathEscape(namespace) + "/secrets"
+	}
+	var secrets []kubeSecret
+	next := ""
+	for {
+		q := url.Values{"fieldSelector": {"type=kubernetes.io/tls"}, "limit": {"500"}}
+		if next != "" {
+			q.Set("continue", next)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", c.server+path+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var list struct {
+			Items    []kubeSecret `json:"items"`
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Message string `json:"message"` // of a Status on errors
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, list.Message)
+		}
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, list.Items...)
+		if next = list.Metadata.Continue; next == "" {
+			return secrets, nil
+		}
+	}
+}
+
+// checkSecrets checks the certificates of the group's Kubernetes secrets.
+// A namespace that cannot be listed is reported as a failed site
+// "k8s://namespace", or "k8s://*" for all namespaces.
+func (group *WatchGroup) checkSecrets(ctx context.Context, today time.Time) []Result {
+	k := group.Kubernetes
+	namespaces := k.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	c, cerr := k.client(group.timeout(&Site{}))
+	var results []Result
+	for _, ns := range namespaces {
+		site := "k8s://" + ns
+		if ns == "" {
+			site += "*"
+		}
+		var secrets []kubeSecret
+		err := cerr
+		if err == nil {
+			secrets, err = c.tlsSecrets(ctx, ns)
+		}
+		if err != nil {
+			slog.Error("failed to list kubernetes secrets:", "site", site, "error", err)
+			results = append(results, Result{Site: site, Status: StatusFailed, Err: &checkError{Phase: "list", Err: err}, Attempts: 1, messages: group.messages})
+			continue
+		}
+		for _, s := range secrets {
+			r := Result{Site: "k8s://" + s.Metadata.Namespace + "/" + s.Metadata.Name, Attempts: 1, messages: group.messages}
+			chain, err := readPEM(s.Data["tls.crt"])
+			if err != nil {
+				r.Status, r.Err = StatusFailed, &checkError{Phase: "read", Err: err}
+				slog.Error("failed to check cert:", "site", r.Site, "error", err)
+			} else {
+				group.grade(&r, group.site(r.Site), chain, today)
+			}
+			results = append(results, r)
+		}
+	}
+	return results
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+var errLocked = errors.New("locked by another instance")
+
+// acquireLock takes an exclusive lock on the file at path, creating it if
+// needed, and writes our pid into it. When another process holds the lock
+// it waits for it if wait is set and fails with errLocked otherwise. The
+// lock lasts until the returned file is closed or the process exits.
+func acquireLock(path string, wait bool) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, wait); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	return f, nil
+}
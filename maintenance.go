@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Window is a maintenance window during which a site's problems are not
+// alerted. Start or End may be left out for an open range; Until is
+// shorthand for End:
+//
+//	maintenance = [{ start = 2026-11-01T22:00:00+08:00, end = 2026-11-02T06:00:00+08:00 }]
+//	maintenance = [{ until = 2026-11-15, reason = "migrating to the new CA" }]
+type Window struct {
+	Start  time.Time `toml:"start"`
+	End    time.Time `toml:"end"`
+	Until  time.Time `toml:"until"`
+	Reason string    `toml:"reason"`
+}
+
+func (w *Window) covers(t time.Time) bool {
+	end := w.End
+	if end.IsZero() {
+		end = w.Until
+	}
+	return (w.Start.IsZero() || !t.Before(w.Start)) && (end.IsZero() || t.Before(end))
+}
+
+// localizeWindows reads the group's and its sites' window times that were
+// written without an offset in the group's timezone.
+func (group *WatchGroup) localizeWindows() {
+	localize := func(windows []Window) {
+		for i := range windows {
+			w := &windows[i]
+			for _, t := range []*time.Time{&w.Start, &w.End, &w.Until} {
+				*t = inLocation(*t, group.loc)
+			}
+		}
+	}
+	localize(group.Maintenance)
+	for i := range group.Sites {
+		localize(group.Sites[i].Maintenance)
+	}
+}
+
+// inLocation moves a TOML local date or datetime, which the decoder places
+// in the host's timezone, to the same wall clock time in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	switch t.Location().String() {
+	case "date-local", "datetime-local":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	}
+	return t
+}
+
+// snooze silences a site's alerts until a time, set from the command line.
+type snooze struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// muted returns why alerts for site are silenced at t, if they are: a
+// maintenance window of the site or the group, or a snooze.
+func (group *WatchGroup) muted(site *Site, t time.Time) (string, bool) {
+	for _, windows := range [][]Window{site.Maintenance, group.Maintenance} {
+		for _, w := range windows {
+			if w.covers(t) {
+				return "maintenance " + w.Reason, true
+			}
+		}
+	}
+	if s, ok := group.config.state.snoozed(site.Addr, t); ok {
+		return "snoozed " + s.Reason, true
+	}
+	return "", false
+}
+
+// withoutMuted drops the alerts of sites that are muted at the report's date,
+// and reports whether anything is left to send, as filterReport does.
+func (group *WatchGroup) withoutMuted(rep *Report) (*Report, bool) {
+	group.config.state.refreshSnoozes()
+	out := *rep
+	out.Results = make([]Result, 0, len(rep.Results))
+	for _, r := range rep.Results {
+		if r.Status != StatusOK {
+			if why, ok := group.muted(group.site(r.addr()), rep.Date); ok {
+				slog.Info("alert muted", "group", group.Name, "site", r.Site, "status", r.Status.String(), "by", strings.TrimSpace(why))
+				continue
+			}
+		}
+		out.Results = append(out.Results, r)
+	}
+	return &out, len(out.Alerts()) > 0 || len(rep.Alerts()) == 0
+}
+
+// site returns the configured site with addr, or one without options for
+// discovered sites such as Kubernetes secrets.
+func (group *WatchGroup) site(addr string) *Site {
+	for i := range group.Sites {
+		if group.Sites[i].Addr == addr {
+			return &group.Sites[i]
+		}
+	}
+	return &Site{Addr: addr}
+}
+
+// snoozed returns the snooze of site in effect at t, if any.
+func (s *stateStore) snoozed(site string, t time.Time) (snooze, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sn, ok := s.Snoozes[site]
+	return sn, ok && t.Before(sn.Until)
+}
+
+// refreshSnoozes picks up snoozes written by another process since the
+// state was loaded.
+func (s *stateStore) refreshSnoozes() {
+	if s.path == "" {
+		return
+	}
+	disk, err := loadState(s.path)
+	if err != nil {
+		slog.Warn("failed to reread state file", "path", s.path, "error", err)
+		return
+	}
+	s.mu.Lock()
+	s.Snoozes = disk.Snoozes
+	s.mu.Unlock()
+}
+
+// snoozeCommand handles "snooze [site for|until [reason...]]" and "unsnooze
+// site": it adds or removes a snooze in the state file, or lists them.
+func snoozeCommand(config *Config, cmd string, args []string) error {
+	if config.StateFile == "" {
+		return fmt.Errorf("snoozing needs state_file in the config")
+	}
+	s := config.state
+	now := time.Now().In(config.loc)
+	for site, sn := range s.Snoozes {
+		if !now.Before(sn.Until) {
+			delete(s.Snoozes, site)
+		}
+	}
+	switch {
+	case cmd == "unsnooze" && len(args) == 1:
+		delete(s.Snoozes, args[0])
+	case cmd == "snooze" && len(args) == 0:
+		sites := make([]string, 0, len(s.Snoozes))
+		for site := range s.Snoozes {
+			sites = append(sites, site)
+		}
+		sort.Strings(sites)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, site := range sites {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", site, s.Snoozes[site].Until.Format(time.DateTime), s.Snoozes[site].Reason)
+		}
+		return w.Flush()
+	case cmd == "snooze" && len(args) >= 2:
+		until, err := parseUntil(args[1], now)
+		if err != nil {
+			return err
+		}
+		s.Snoozes[args[0]] = snooze{Until: until, Reason: strings.Join(args[2:], " ")}
+		fmt.Printf("%s snoozed until %s\n", args[0], until.Format(time.DateTime))
+	default:
+		return fmt.Errorf("usage: crtwtch [-c config] snooze [site 7d|48h|2006-01-02 [reason...]] | unsnooze site")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save()
+	return nil
+}
+
+// parseUntil reads a snooze end: a duration from now such as "48h" or "7d",
+// or a date.
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil && n > 0 {
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("bad snooze time %q: want a duration like 48h or 7d, or a date", s)
+}
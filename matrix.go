@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterNotifier("matrix", func(decode func(any) error) (Notifier, error) {
+		n := &MatrixNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.Homeserver == "" || n.AccessToken == "" || n.RoomID == "" {
+			return nil, fmt.Errorf("matrix: homeserver, access_token and room_id are required")
+		}
+		n.Homeserver = strings.TrimRight(n.Homeserver, "/")
+		return n, nil
+	})
+}
+
+// MatrixNotifier posts HTML formatted messages to a Matrix room.
+type MatrixNotifier struct {
+	Homeserver  string `toml:"homeserver"`
+	AccessToken string `toml:"access_token"`
+	RoomID      string `toml:"room_id"`
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+func (n *MatrixNotifier) Send(msg string, level slog.Level) error {
+	formatted := strings.ReplaceAll(html.EscapeString(msg), "\n", "<br>")
+	return n.post(msg, formatted)
+}
+
+func (n *MatrixNotifier) SendReport(rep *Report) error {
+	var b strings.Builder
+	b.WriteString("<strong>" + html.EscapeString(rep.Title()) + "</strong>")
+	if shown := rep.Shown(); len(shown) > 0 {
+		b.WriteString("<ul>")
+		for _, r := range shown {
+			b.WriteString("<li>" + html.EscapeString(r.Message()) + "</li>")
+		}
+		b.WriteString("</ul>")
+	}
+	return n.post(rep.Text(), b.String())
+}
+
+func (n *MatrixNotifier) post(body, formatted string) error {
+	txn := "crtwtch-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	u := n.Homeserver + "/_matrix/client/v3/rooms/" + url.PathEscape(n.RoomID) + "/send/m.room.message/" + txn
+	header := map[string]string{"Authorization": "Bearer " + n.AccessToken}
+	status, resp, err := sendJSON("PUT", u, header, matrixMessage{
+		MsgType:       "m.text",
+		Body:          body,
+		Format:        "org.matrix.custom.html",
+		FormattedBody: formatted,
+	})
+	if err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	if status != 200 {
+		return fmt.Errorf("matrix: unexpected status code %d: %s", status, resp)
+	}
+	return nil
+}
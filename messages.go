@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MessageTemplates overrides the wording of a group's notifications with
+// text/template strings. Title is executed with the Report as dot, the
+// others with the Result of one site (.Site, .DaysLeft, .Expiry, .Issuer,
+// .Left, .HoursLeft and .InHours, which is true under 48 hours left,
+// .Status, .Err, .TimedOut, .Attempts, and .Intermediate and .Subject when
+// a CA certificate of the chain expires first, .Reason for untrusted,
+// mismatch, revoked, ocsp_failed, staple, ct, unexpected_issuer,
+// pin_mismatch, hygiene, tls_policy, self_signed, validity, dane,
+// renewal_due, drift, duplicate and slow, and .TLSVersion, .CipherSuite,
+// .Connect and .Handshake for servers, .IP for sites checked on all their
+// IPs, .MX for the mail hosts of mx: sites, .SANs and .ChainLength);
+// Renewed also has .PrevExpiry and IssuerChanged .PrevIssuer. Details is
+// added under the alerts of groups with details on.
+// Empty fields keep the built-in wording of the group's language.
+type MessageTemplates struct {
+	Title     string `toml:"title"`
+	OK        string `toml:"ok"`
+	Warning   string `toml:"warning"`
+	Critical  string `toml:"critical"`
+	Expired   string `toml:"expired"`
+	Failed    string `toml:"failed"`
+	Skipped   string `toml:"skipped"`
+	Untrusted string `toml:"untrusted"`
+	Mismatch  string `toml:"mismatch"`
+	Revoked   string `toml:"revoked"`
+	// OCSPFailed is for sites whose OCSP responder could not tell.
+	OCSPFailed string `toml:"ocsp_failed"`
+	Staple     string `toml:"staple"`
+	CT         string `toml:"ct"`
+	// UnexpectedIssuer is for certificates the CT monitor found.
+	UnexpectedIssuer string `toml:"unexpected_issuer"`
+	PinMismatch      string `toml:"pin_mismatch"`
+	Hygiene          string `toml:"hygiene"`
+	TLSPolicy        string `toml:"tls_policy"`
+	SelfSigned       string `toml:"self_signed"`
+	Validity         string `toml:"validity"`
+	DANE             string `toml:"dane"`
+	RenewalDue       string `toml:"renewal_due"`
+	Drift            string `toml:"drift"`
+	Duplicate        string `toml:"duplicate"`
+	Slow             string `toml:"slow"`
+	Details          string `toml:"details"`
+	Renewed          string `toml:"renewed"`
+	IssuerChanged    string `toml:"issuer_changed"`
+}
+
+var templateFuncs = template.FuncMap{
+	"date":  func(t time.Time) string { return t.Format("2006-01-02") },
+	"names": shortNames,
+}
+
+// maxNames is how many names shortNames lists.
+const maxNames = 3
+
+// shortNames lists the first few of names, with how many there are when
+// that is not all of them.
+func shortNames(names []string) string {
+	if len(names) <= maxNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s … (%d)", strings.Join(names[:maxNames], ", "), len(names))
+}
+
+// messageSet is a parsed MessageTemplates together with the labels of its
+// language; status is indexed by Status.
+type messageSet struct {
+	title         *template.Template
+	status        [numStatus]*template.Template
+	renewed       *template.Template
+	issuerChanged *template.Template
+	details       *template.Template
+	labels        *labelSet
+	detailed      bool // alerts get the details line
+}
+
+var defaultMessages = catalogs[defaultLang]
+
+func mustMessageSet(t MessageTemplates, labels *labelSet) *messageSet {
+	m, err := newMessageSet(t, nil)
+	if err != nil {
+		panic(err)
+	}
+	m.labels = labels
+	return m
+}
+
+// newMessageSet parses t, taking empty fields from base, which may only be
+// nil when t is complete. Every template is tried on sample data so
+// misspelled fields are reported at load time instead of at alert time.
+func newMessageSet(t MessageTemplates, base *messageSet) (*messageSet, error) {
+	m := &messageSet{}
+	var errs []error
+	parse := func(name, text string, fallback *template.Template) *template.Template {
+		if text == "" {
+			return fallback
+		}
+		tpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return tpl
+	}
+	var fallback [numStatus]*template.Template
+	var title, renewed, issuerChanged, details *template.Template
+	if base != nil {
+		fallback, title, renewed, issuerChanged, details = base.status, base.title, base.renewed, base.issuerChanged, base.details
+		m.labels = base.labels
+	}
+	m.title = parse("title", t.Title, title)
+	m.status[StatusOK] = parse("ok", t.OK, fallback[StatusOK])
+	m.status[StatusWarning] = parse("warning", t.Warning, fallback[StatusWarning])
+	m.status[StatusCritical] = parse("critical", t.Critical, fallback[StatusCritical])
+	m.status[StatusExpired] = parse("expired", t.Expired, fallback[StatusExpired])
+	m.status[StatusFailed] = parse("failed", t.Failed, fallback[StatusFailed])
+	m.status[StatusSkipped] = parse("skipped", t.Skipped, fallback[StatusSkipped])
+	m.status[StatusUntrusted] = parse("untrusted", t.Untrusted, fallback[StatusUntrusted])
+	m.status[StatusMismatch] = parse("mismatch", t.Mismatch, fallback[StatusMismatch])
+	m.status[StatusRevoked] = parse("revoked", t.Revoked, fallback[StatusRevoked])
+	m.status[StatusOCSPFailed] = parse("ocsp_failed", t.OCSPFailed, fallback[StatusOCSPFailed])
+	m.status[StatusStaple] = parse("staple", t.Staple, fallback[StatusStaple])
+	m.status[StatusCT] = parse("ct", t.CT, fallback[StatusCT])
+	m.status[StatusUnexpectedIssuer] = parse("unexpected_issuer", t.UnexpectedIssuer, fallback[StatusUnexpectedIssuer])
+	m.status[StatusPinMismatch] = parse("pin_mismatch", t.PinMismatch, fallback[StatusPinMismatch])
+	m.status[StatusHygiene] = parse("hygiene", t.Hygiene, fallback[StatusHygiene])
+	m.status[StatusTLSPolicy] = parse("tls_policy", t.TLSPolicy, fallback[StatusTLSPolicy])
+	m.status[StatusSelfSigned] = parse("self_signed", t.SelfSigned, fallback[StatusSelfSigned])
+	m.status[StatusValidity] = parse("validity", t.Validity, fallback[StatusValidity])
+	m.status[StatusDANE] = parse("dane", t.DANE, fallback[StatusDANE])
+	m.status[StatusRenewalDue] = parse("renewal_due", t.RenewalDue, fallback[StatusRenewalDue])
+	m.status[StatusDrift] = parse("drift", t.Drift, fallback[StatusDrift])
+	m.status[StatusDuplicate] = parse("duplicate", t.Duplicate, fallback[StatusDuplicate])
+	m.status[StatusSlow] = parse("slow", t.Slow, fallback[StatusSlow])
+	m.renewed = parse("renewed", t.Renewed, renewed)
+	m.issuerChanged = parse("issuer_changed", t.IssuerChanged, issuerChanged)
+	m.details = parse("details", t.Details, details)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return m, m.try()
+}
+
+func (m *messageSet) try() error {
+	sample := Result{Site: "example.com", Expiry: time.Now(), PrevExpiry: time.Now(), Issuer: "Example CA", IssuerDN: "CN=Example CA", PrevIssuer: "CN=Old CA", SANs: []string{"example.com"}, ChainLength: 2, Err: errors.New("example")}
+	rep := &Report{Group: "example", Date: time.Now(), Results: []Result{sample}}
+	var b strings.Builder
+	if err := m.title.Execute(&b, rep); err != nil {
+		return err
+	}
+	for _, tpl := range append(m.status[:], m.renewed, m.issuerChanged, m.details) {
+		if err := tpl.Execute(&b, &sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *messageSet) render(tpl *template.Template, data any) string {
+	var b strings.Builder
+	if err := tpl.Execute(&b, data); err != nil {
+		slog.Error("failed to render message template", "template", tpl.Name(), "error", err)
+		return fmt.Sprintf("[%s: %v]", tpl.Name(), err)
+	}
+	return b.String()
+}
+
+func (m *messageSet) resultLine(r *Result) string {
+	if m == nil {
+		m = defaultMessages
+	}
+	line := m.render(m.status[r.Status], r)
+	// only certificates that were found have details to show
+	if m.detailed && r.Status != StatusOK && r.Err == nil && r.ChainLength > 0 {
+		line += "\n" + m.render(m.details, r)
+	}
+	return line
+}
+
+func (m *messageSet) renewedLine(r *Result) string {
+	if m == nil {
+		m = defaultMessages
+	}
+	return m.render(m.renewed, r)
+}
+
+func (m *messageSet) issuerChangedLine(r *Result) string {
+	if m == nil {
+		m = defaultMessages
+	}
+	return m.render(m.issuerChanged, r)
+}
+
+func (m *messageSet) reportTitle(rep *Report) string {
+	if m == nil {
+		m = defaultMessages
+	}
+	return m.render(m.title, rep)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsStore keeps the results of the last pass of each group for the
+// Prometheus exporter, in the text exposition format. Every pass replaces
+// its group's results, so sites taken out of a group disappear with it.
+type metricsStore struct {
+	mu     sync.Mutex
+	groups map[string]metricsPass
+}
+
+type metricsPass struct {
+	date    time.Time
+	results []Result
+}
+
+var metrics = &metricsStore{groups: map[string]metricsPass{}}
+
+// record keeps the results of rep as those of group.
+func (m *metricsStore) record(group string, rep *Report) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.groups[group] = metricsPass{date: rep.Date, results: slices.Clone(rep.Results)}
+}
+
+// each calls f with every result of the groups' last passes.
+func (m *metricsStore) each(f func(group string, r *Result)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for group, pass := range m.groups {
+		for i := range pass.results {
+			f(group, &pass.results[i])
+		}
+	}
+}
+
+// retain forgets the groups not among names, after a reload removed them.
+func (m *metricsStore) retain(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.groups {
+		if !slices.Contains(names, name) {
+			delete(m.groups, name)
+		}
+	}
+}
+
+// resultMetrics are the metrics written per result, in order. sample
+// returns the labels the metric adds to group and site, and its value; ok
+// is false for results the metric does not apply to.
+var resultMetrics = []struct {
+	name, help string
+	sample     func(r *Result) (labels string, v float64, ok bool)
+}{
+	{"crtwtch_cert_not_after_timestamp_seconds", "When the certificate that expires first in the chain expires.", func(r *Result) (string, float64, bool) {
+		return "", float64(r.Expiry.Unix()), !r.Expiry.IsZero()
+	}},
+	{"crtwtch_cert_days_left", "Whole days until the certificate that expires first in the chain expires.", func(r *Result) (string, float64, bool) {
+		return "", float64(r.DaysLeft), !r.Expiry.IsZero()
+	}},
+	{"crtwtch_check_success", "Whether a certificate could be read from the site.", func(r *Result) (string, float64, bool) {
+		if r.Err != nil || r.Status == StatusSkipped {
+			return "", 0, true
+		}
+		return "", 1, true
+	}},
+	{"crtwtch_check_status", "The status of the site, 1 for the one it has.", func(r *Result) (string, float64, bool) {
+		return ",status=" + metricLabel(r.Status.String()), 1, true
+	}},
+	{"crtwtch_check_duration_seconds", "How long checking the site took, retries included.", func(r *Result) (string, float64, bool) {
+		return "", r.Duration.Seconds(), r.Duration > 0
+	}},
+	{"crtwtch_tls_handshake_seconds", "How long the TLS handshake took.", func(r *Result) (string, float64, bool) {
+		return "", r.Handshake.Seconds(), r.Handshake > 0
+	}},
+}
+
+// write writes the metrics of every group in the Prometheus text format,
+// groups sorted by name.
+func (m *metricsStore) write(w io.Writer) error {
+	m.mu.Lock()
+	groups := make([]string, 0, len(m.groups))
+	for name := range m.groups {
+		groups = append(groups, name)
+	}
+	m.mu.Unlock()
+	slices.Sort(groups)
+	return m.writeGroups(w, groups)
+}
+
+// writeGroups writes the metrics of groups, skipping those without a pass.
+func (m *metricsStore) writeGroups(w io.Writer, groups []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	groups = slices.DeleteFunc(slices.Clone(groups), func(name string) bool {
+		_, ok := m.groups[name]
+		return !ok
+	})
+	var b strings.Builder
+	for _, metric := range resultMetrics {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name)
+		for _, group := range groups {
+			for i := range m.groups[group].results {
+				r := &m.groups[group].results[i]
+				if labels, v, ok := metric.sample(r); ok {
+					fmt.Fprintf(&b, "%s{group=%s,site=%s%s} %s\n", metric.name, metricLabel(group), metricLabel(r.Site), labels, strconv.FormatFloat(v, 'f', -1, 64))
+				}
+			}
+		}
+	}
+	b.WriteString("# HELP crtwtch_last_run_timestamp_seconds When the group was last checked.\n# TYPE crtwtch_last_run_timestamp_seconds gauge\n")
+	for _, group := range groups {
+		fmt.Fprintf(&b, "crtwtch_last_run_timestamp_seconds{group=%s} %d\n", metricLabel(group), m.groups[group].date.Unix())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// textfileName is the file written for node_exporter's textfile collector,
+// which reads the *.prom files of its directory.
+const textfileName = "crtwtch.prom"
+
+// writeTextfile writes the metrics to textfileName in dir, atomically so
+// node_exporter never scrapes half a file.
+func (m *metricsStore) writeTextfile(dir string) error {
+	var b strings.Builder
+	if err := m.write(&b); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, textfileName), []byte(b.String()), 0o644)
+}
+
+// metricLabel quotes a label value as the text format wants it.
+func metricLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+// metricsListener runs serveMetrics on the address of the current config,
+// moving it when a reload changes metrics_listen.
+type metricsListener struct {
+	addr   string
+	cancel context.CancelFunc
+}
+
+// listen serves on addr from now on, none when empty.
+func (l *metricsListener) listen(ctx context.Context, addr string) {
+	if addr == l.addr {
+		return
+	}
+	l.stop()
+	l.addr = addr
+	if addr == "" {
+		return
+	}
+	ctx, l.cancel = context.WithCancel(ctx)
+	go serveMetrics(ctx, addr)
+}
+
+func (l *metricsListener) stop() {
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+}
+
+// serveMetrics serves /metrics on addr until ctx is done.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := metrics.write(w); err != nil {
+			slog.Warn("failed to write metrics", "error", err)
+		}
+	})
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	slog.Info("serving metrics", "addr", addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("metrics server failed", "addr", addr, "error", err)
+	}
+}
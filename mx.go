@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// mxDomain returns the domain of an "mx:example.com" site, whose mail hosts
+// are looked up on every check.
+func mxDomain(addr string) (string, bool) {
+	if len(addr) < 3 || !strings.EqualFold(addr[:3], "mx:") {
+		return "", false
+	}
+	return addr[3:], true
+}
+
+func validMXDomain(domain string) error {
+	if domain == "" || strings.ContainsAny(domain, ":/[]") || net.ParseIP(domain) != nil {
+		return fmt.Errorf("mx: wants a bare domain, got %q", domain)
+	}
+	return nil
+}
+
+// checkMX checks each mail host the site's domain has MX records for over
+// SMTP with STARTTLS, so new and retired mail hosts are picked up without
+// editing the sites list. Each result is named after the site with the mail
+// host in brackets, as in "mx:example.com [mx1.example.com]", and the site's
+// options, all_ips among them, apply to every host.
+func (group *WatchGroup) checkMX(ctx context.Context, site *Site, today time.Time) []Result {
+	domain, _ := mxDomain(site.Addr)
+	resolveCtx, cancel := context.WithTimeout(ctx, group.timeout(site))
+	mxs, err := net.DefaultResolver.LookupMX(resolveCtx, domain)
+	cancel()
+	if err != nil {
+		slog.Error("failed to look up MX records:", "site", site, "error", err)
+		return []Result{{Site: site.Addr, Status: StatusFailed, Err: &checkError{Phase: "resolve", Err: err}, Attempts: 1, messages: group.messages}}
+	}
+	var results []Result
+	for _, mx := range mxs {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" { // a null MX, the domain takes no mail
+			continue
+		}
+		s := *site
+		s.Addr, s.ServerName = "smtp://"+host, ""
+		var rs []Result
+		if group.checksAllIPs(&s) {
+			rs = group.checkIPs(ctx, &s, today)
+		} else {
+			rs = []Result{group.check(ctx, &s, today)}
+		}
+		for _, r := range rs {
+			r.Site, r.MX = site.Addr+" ["+host+"]", host
+			if r.IP != "" {
+				r.Site += " [" + r.IP + "]"
+			}
+			results = append(results, r)
+		}
+	}
+	if len(results) == 0 {
+		slog.Info("domain has no mail hosts", "site", site)
+	}
+	return results
+}
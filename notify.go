@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Notifier delivers a rendered alert message to one channel.
+type Notifier interface {
+	Send(msg string, level slog.Level) error
+}
+
+// ReportNotifier is implemented by notifiers that render the structured
+// report themselves instead of taking the plain text message.
+type ReportNotifier interface {
+	Notifier
+	SendReport(rep *Report) error
+}
+
+// Resolver is implemented by incident notifiers that keep an alert open per
+// site. They get renewal notices as resolutions instead of messages.
+type Resolver interface {
+	Notifier
+	Resolve(group string, renewed []Result) error
+}
+
+// NotifierFactory builds a notifier from its [[groups.notifiers]] table.
+// decode fills the given struct with the table's keys.
+type NotifierFactory func(decode func(v any) error) (Notifier, error)
+
+var notifierFactories = map[string]NotifierFactory{}
+
+// RegisterNotifier makes a notifier type available to the config file.
+// It is meant to be called from init and panics on duplicates.
+func RegisterNotifier(typ string, factory NotifierFactory) {
+	if _, ok := notifierFactories[typ]; ok {
+		panic("notifier already registered: " + typ)
+	}
+	notifierFactories[typ] = factory
+}
+
+// channel is a configured notifier together with the name it is logged as.
+type channel struct {
+	name string
+	Notifier
+}
+
+// notifierHead holds the keys shared by every [[groups.notifiers]] table.
+type notifierHead struct {
+	Type string `toml:"type"`
+	Name string `toml:"name"`
+}
+
+func newNotifier(md toml.MetaData, prim toml.Primitive) (notifierHead, Notifier, error) {
+	var head notifierHead
+	if err := md.PrimitiveDecode(prim, &head); err != nil {
+		return head, nil, err
+	}
+	factory, ok := notifierFactories[head.Type]
+	if !ok {
+		return head, nil, fmt.Errorf("unknown notifier type %q", head.Type)
+	}
+	n, err := factory(func(v any) error { return md.PrimitiveDecode(prim, v) })
+	return head, n, err
+}
+
+// setupNotifiers builds the group's notifiers from the per-channel shorthand
+// fields and the [[groups.notifiers]] list.
+func (group *WatchGroup) setupNotifiers(md toml.MetaData) error {
+	group.notifiers = nil
+	add := func(name string, n Notifier) {
+		group.notifiers = append(group.notifiers, channel{name: name, Notifier: n})
+	}
+	if group.WxworkToken != "" {
+		n := &WxworkNotifier{
+			Token:               group.WxworkToken,
+			MsgType:             group.WxworkMsgType,
+			MentionedList:       group.MentionedList,
+			MentionedMobileList: group.MentionedMobileList,
+		}
+		if err := n.validate(); err != nil {
+			return fmt.Errorf("group %s: %w", group.Name, err)
+		}
+		add("wxwork", n)
+	}
+	if group.SlackWebhookURL != "" {
+		add("slack", &SlackNotifier{WebhookURL: group.SlackWebhookURL})
+	}
+	if group.DingtalkToken != "" {
+		add("dingtalk", &DingtalkNotifier{Token: group.DingtalkToken, Secret: group.DingtalkSecret})
+	}
+	if group.TelegramBotToken != "" {
+		if group.TelegramChatID == "" {
+			return fmt.Errorf("group %s: telegram_chat_id is required with telegram_bot_token", group.Name)
+		}
+		add("telegram", &TelegramNotifier{BotToken: group.TelegramBotToken, ChatID: group.TelegramChatID})
+	}
+	if group.DiscordWebhookURL != "" {
+		add("discord", &DiscordNotifier{WebhookURL: group.DiscordWebhookURL})
+	}
+	for i, prim := range group.Notifiers {
+		head, n, err := newNotifier(md, prim)
+		if err != nil {
+			return fmt.Errorf("group %s notifier #%d: %w", group.Name, i+1, err)
+		}
+		if head.Name == "" {
+			head.Name = fmt.Sprintf("%s#%d", head.Type, i+1)
+		}
+		add(head.Name, n)
+	}
+	return group.setupRoutes()
+}
+
+// severityAliases are the named severities accepted as route keys besides
+// slog level names.
+var severityAliases = map[string]slog.Level{
+	"ok":       slog.LevelInfo,
+	"warning":  slog.LevelWarn,
+	"critical": slog.LevelError,
+}
+
+func parseSeverity(s string) (slog.Level, error) {
+	if level, ok := severityAliases[strings.ToLower(s)]; ok {
+		return level, nil
+	}
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+// setupRoutes resolves the group's routes table to channels.
+func (group *WatchGroup) setupRoutes() error {
+	group.routes = nil
+	if len(group.Routes) == 0 {
+		return nil
+	}
+	byName := make(map[string]channel, len(group.notifiers))
+	for _, ch := range group.notifiers {
+		byName[ch.name] = ch
+	}
+	group.routes = make(map[slog.Level][]channel, len(group.Routes))
+	for key, names := range group.Routes {
+		level, err := parseSeverity(key)
+		if err != nil {
+			return fmt.Errorf("group %s routes: %w", group.Name, err)
+		}
+		if _, dup := group.routes[level]; dup {
+			return fmt.Errorf("group %s routes: severity %s listed twice", group.Name, level)
+		}
+		chans := make([]channel, 0, len(names))
+		for _, name := range names {
+			ch, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("group %s routes: unknown notifier %q", group.Name, name)
+			}
+			chans = append(chans, ch)
+		}
+		group.routes[level] = chans
+	}
+	return nil
+}
+
+// channelsFor returns the channels a report of the given level goes to:
+// the route for that level if one is configured, every channel otherwise.
+func (group *WatchGroup) channelsFor(level slog.Level) []channel {
+	if chans, ok := group.routes[level]; ok {
+		return chans
+	}
+	return group.notifiers
+}
+
+// notify sends rep through every notifier of the group concurrently. Each
+// failed channel is logged and included in the returned error.
+func (group *WatchGroup) notify(rep *Report) error {
+	group.markRecovered(rep)
+	level := rep.Level()
+	return group.deliver(level, rep.Text(), func(ch channel) error {
+		if rn, ok := ch.Notifier.(ReportNotifier); ok {
+			return rn.SendReport(rep)
+		}
+		return ch.Send(rep.Text(), level)
+	})
+}
+
+// notifyText sends a plain message, such as a summary that is not a Report,
+// through the group's channels for level.
+func (group *WatchGroup) notifyText(msg string, level slog.Level) error {
+	return group.deliver(level, msg, func(ch channel) error { return ch.Send(msg, level) })
+}
+
+// deliver calls send for every channel routed for level concurrently, with
+// retries. Channels that still fail are logged, get text dead-lettered and
+// are included in the returned error.
+func (group *WatchGroup) deliver(level slog.Level, text string, send func(ch channel) error) error {
+	if len(group.notifiers) == 0 {
+		slog.Warn("no notifier configured, skipping notification", "group", group.Name)
+		return nil
+	}
+	chans := group.channelsFor(level)
+	errs := make([]error, len(chans))
+	var wg sync.WaitGroup
+	for i, ch := range chans {
+		wg.Go(func() {
+			retry := &group.config.Retry
+			err := group.traceNotify(ch, level, func() error {
+				return retry.do(group.Name, ch.name, func() error { return send(ch) })
+			})
+			if err != nil {
+				slog.Error("notification failed", "group", group.Name, "notifier", ch.name, "error", err)
+				errs[i] = fmt.Errorf("%s: %w", ch.name, err)
+				retry.bury(deadLetter{
+					Time:     time.Now(),
+					Group:    group.Name,
+					Notifier: ch.name,
+					Level:    level.String(),
+					Message:  text,
+					Error:    err.Error(),
+				})
+				return
+			}
+			slog.Info("notification sent successfully", "group", group.Name, "notifier", ch.name, "level", level.String())
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// splitMessage splits a rendered message into its first line and the rest,
+// for channels that take a separate title. Single-line messages are used as
+// both.
+func splitMessage(msg string) (title, body string) {
+	title, body, ok := strings.Cut(msg, "\n")
+	if !ok {
+		body = title
+	}
+	return title, body
+}
+
+// chunkMessage splits msg on line boundaries into parts of at most limit
+// bytes, for channels that reject long messages. When more than one part is
+// needed each one starts with a "(i/n)" line. Single lines longer than a
+// part are truncated.
+func chunkMessage(msg string, limit int) []string {
+	if len(msg) <= limit {
+		return []string{msg}
+	}
+	const header = len("(999/999)\n")
+	size := limit - header
+	var parts []string
+	var cur strings.Builder
+	for _, line := range strings.Split(msg, "\n") {
+		line = truncateBytes(line, size)
+		if cur.Len() > 0 && cur.Len()+1+len(line) > size {
+			parts = append(parts, cur.String())
+			cur.Reset()
+		}
+		if cur.Len() > 0 {
+			cur.WriteByte('\n')
+		}
+		cur.WriteString(line)
+	}
+	parts = append(parts, cur.String())
+	for i := range parts {
+		parts[i] = fmt.Sprintf("(%d/%d)\n%s", i+1, len(parts), parts[i])
+	}
+	return parts
+}
+
+// truncateBytes shortens s to at most n bytes without splitting a rune.
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// postJSON posts v encoded as JSON to url and returns the response body.
+// Any status other than 200 is reported as an error.
+func postJSON(url string, v any) ([]byte, error) {
+	status, body, err := sendJSON("POST", url, nil, v)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return body, fmt.Errorf("unexpected status code: %d", status)
+	}
+	return body, nil
+}
+
+// sendJSON sends v encoded as JSON and returns the status code and response
+// body. Notifiers build payloads through here rather than by formatting
+// strings, so message text is always escaped correctly.
+func sendJSON(method, url string, header map[string]string, v any) (int, []byte, error) {
+	payload, err := marshalJSON(v)
+	if err != nil {
+		return 0, nil, err
+	}
+	h := map[string]string{"Content-Type": "application/json"}
+	for k, val := range header {
+		h[k] = val
+	}
+	return doHTTP(method, url, h, payload)
+}
+
+// marshalJSON encodes v without escaping <, > and &, which several chat
+// APIs use for markup and which make logged payloads hard to read.
+func marshalJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// doHTTP sends one request and returns the status code and response body.
+func doHTTP(method, url string, header map[string]string, payload string) (int, []byte, error) {
+	req, err := http.NewRequest(method, url, strings.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	slog.Debug("http request", "method", method, "url", req.URL.Redacted(), "data", payload)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hoursCases are results either side of hoursWindow, with the time left
+// each renderer should show for them.
+var hoursCases = []struct {
+	left  time.Duration
+	label string
+	n     string
+}{
+	{6 * time.Hour, "剩余小时", "6"},
+	{47 * time.Hour, "剩余小时", "47"},
+	{48 * time.Hour, "剩余天数", "2"},
+}
+
+func hoursResult(left time.Duration) Result {
+	return Result{
+		Site:     "example.com",
+		Expiry:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		Left:     left,
+		DaysLeft: daysOf(left),
+		Status:   StatusCritical,
+	}
+}
+
+func TestDiscordEmbedHours(t *testing.T) {
+	for _, tt := range hoursCases {
+		r := hoursResult(tt.left)
+		var found bool
+		for _, f := range discordEmbedOf(&r).Fields {
+			if f.Name == tt.label {
+				found = true
+				if f.Value != tt.n {
+					t.Errorf("%v left: %s = %q, want %q", tt.left, f.Name, f.Value, tt.n)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%v left: no %s field", tt.left, tt.label)
+		}
+	}
+}
+
+func TestEmailRowHours(t *testing.T) {
+	for _, tt := range hoursCases {
+		r := hoursResult(tt.left)
+		want := tt.n
+		if r.InHours() {
+			want += " (" + tt.label + ")"
+		}
+		if got := emailRowOf(&r).DaysLeft; got != want {
+			t.Errorf("%v left: days left cell = %q, want %q", tt.left, got, want)
+		}
+	}
+}
+
+func TestFeishuReportHours(t *testing.T) {
+	var cells []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Card struct {
+				Elements []struct {
+					Rows []map[string]string `json:"rows"`
+				} `json:"elements"`
+			} `json:"card"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Errorf("body is not a feishu card: %v\n%s", err, body)
+		}
+		for _, e := range msg.Card.Elements {
+			for _, row := range e.Rows {
+				cells = append(cells, row["days_left"])
+			}
+		}
+		io.WriteString(w, `{"code":0,"msg":"ok"}`)
+	}))
+	defer srv.Close()
+	rep := &Report{Group: "g", Date: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)}
+	for _, tt := range hoursCases {
+		rep.Results = append(rep.Results, hoursResult(tt.left))
+	}
+	n := &FeishuNotifier{WebhookURL: srv.URL}
+	if err := n.SendReport(rep); err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != len(hoursCases) {
+		t.Fatalf("got %d rows, want %d", len(cells), len(hoursCases))
+	}
+	for i, tt := range hoursCases {
+		want := "**" + tt.n
+		if tt.label == "剩余小时" {
+			want += " (" + tt.label + ")"
+		}
+		if !strings.Contains(cells[i], want+"**") {
+			t.Errorf("%v left: days left cell = %q, want %s**", tt.left, cells[i], want)
+		}
+	}
+}
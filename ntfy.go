@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("ntfy", func(decode func(any) error) (Notifier, error) {
+		n := &NtfyNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.Topic == "" {
+			return nil, fmt.Errorf("ntfy: topic is required")
+		}
+		if n.Server == "" {
+			n.Server = "https://ntfy.sh"
+		}
+		n.Server = strings.TrimRight(n.Server, "/")
+		return n, nil
+	})
+}
+
+// NtfyNotifier publishes to an ntfy topic, on ntfy.sh or a self-hosted server.
+type NtfyNotifier struct {
+	Server string `toml:"server"`
+	Topic  string `toml:"topic"`
+	Token  string `toml:"token"`
+}
+
+type ntfyMessage struct {
+	Topic    string   `json:"topic"`
+	Title    string   `json:"title"`
+	Message  string   `json:"message"`
+	Priority int      `json:"priority"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// ntfyPriority maps levels onto ntfy's 1 (min) to 5 (max) scale.
+func ntfyPriority(level slog.Level) (int, []string) {
+	switch {
+	case level >= slog.LevelError:
+		return 5, []string{"rotating_light"}
+	case level >= slog.LevelWarn:
+		return 4, []string{"warning"}
+	}
+	return 2, []string{"white_check_mark"}
+}
+
+func (n *NtfyNotifier) Send(msg string, level slog.Level) error {
+	title, body := splitMessage(msg)
+	priority, tags := ntfyPriority(level)
+	header := map[string]string{}
+	if n.Token != "" {
+		header["Authorization"] = "Bearer " + n.Token
+	}
+	status, resp, err := sendJSON("POST", n.Server, header, ntfyMessage{Topic: n.Topic, Title: title, Message: body, Priority: priority, Tags: tags})
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	if status != 200 {
+		return fmt.Errorf("ntfy: unexpected status code %d: %s", status, resp)
+	}
+	return nil
+}
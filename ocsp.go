@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// checksOCSP reports whether the group asks OCSP responders about its
+// certificates: its own ocsp setting if it has one, else the global one.
+func (group *WatchGroup) checksOCSP() bool {
+	if group.OCSP != nil {
+		return *group.OCSP
+	}
+	return group.config.OCSP
+}
+
+// checkRevocation asks the OCSP responder of the leaf of chain whether it is
+// revoked. A revoked certificate trumps everything but expiry; a responder
+// that cannot tell is only reported for sites that are fine otherwise, so
+// it does not hide a more pressing alert. Certificates that name no
+// responder are not checked.
+func (group *WatchGroup) checkRevocation(ctx context.Context, r *Result, chain []*x509.Certificate, timeout time.Duration) {
+	resp, err := ocspStatus(ctx, group.httpClient(timeout), chain)
+	if err == nil && resp != nil && resp.Status == ocsp.Unknown {
+		err = errOCSPUnknown
+	}
+	switch {
+	case err != nil:
+		slog.Warn("OCSP check failed", "site", r.Site, "error", err)
+		if r.Status == StatusOK {
+			r.Status, r.Reason = StatusOCSPFailed, err.Error()
+		}
+	case resp != nil && resp.Status == ocsp.Revoked:
+		group.revoked(r, resp)
+	}
+}
+
+func (group *WatchGroup) revoked(r *Result, resp *ocsp.Response) {
+	r.Status, r.Reason = StatusRevoked, fmt.Sprintf("revoked on %s", resp.RevokedAt.In(group.loc).Format("2006-01-02"))
+	if name := revocationReasons[resp.RevocationReason]; name != "" {
+		r.Reason += " (" + name + ")"
+	}
+	slog.Warn("certificate is revoked", "site", r.Site, "reason", r.Reason)
+}
+
+// checkStaple checks the OCSP response the server stapled to the handshake
+// and returns it if it is fresh and says the certificate is good or revoked,
+// marking r in the latter case. A missing, stale or broken staple is only
+// alerted for certificates that demand one with the Must-Staple extension,
+// since browsers refuse those without it.
+func (group *WatchGroup) checkStaple(r *Result, chain []*x509.Certificate, staple []byte) *ocsp.Response {
+	leaf := chain[0]
+	problem := "no OCSP response stapled"
+	if len(staple) > 0 {
+		resp, err := ocsp.ParseResponseForCert(staple, leaf, chainIssuer(chain))
+		switch {
+		case err != nil:
+			problem = "bad stapled OCSP response: " + err.Error()
+		case !resp.NextUpdate.IsZero() && time.Now().After(resp.NextUpdate):
+			problem = "stapled OCSP response expired on " + resp.NextUpdate.In(group.loc).Format(time.DateTime)
+		case resp.Status == ocsp.Revoked:
+			group.revoked(r, resp)
+			return resp
+		case resp.Status == ocsp.Good:
+			return resp
+		default:
+			problem = "stapled OCSP response: " + errOCSPUnknown.Error()
+		}
+	}
+	switch {
+	case mustStaple(leaf):
+		r.Status, r.Reason = StatusStaple, problem
+		slog.Warn("Must-Staple certificate without a valid staple", "site", r.Site, "problem", problem)
+	case len(staple) > 0:
+		slog.Warn("server staples an unusable OCSP response", "site", r.Site, "problem", problem)
+	}
+	return nil
+}
+
+// oidTLSFeature is the TLS Feature extension of RFC 7633; listing the
+// status_request feature (5) in it makes the certificate Must-Staple.
+var oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+func mustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidTLSFeature) {
+			continue
+		}
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err == nil && slices.Contains(features, 5) {
+			return true
+		}
+	}
+	return false
+}
+
+// chainIssuer returns the issuer of the leaf of chain if the chain has it.
+func chainIssuer(chain []*x509.Certificate) *x509.Certificate {
+	if len(chain) > 1 && chain[0].CheckSignatureFrom(chain[1]) == nil {
+		return chain[1]
+	}
+	return nil
+}
+
+var errOCSPUnknown = errors.New("the OCSP responder does not know the certificate")
+
+// revocationReasons names the CRL reason codes of RFC 5280.
+var revocationReasons = map[int]string{
+	ocsp.KeyCompromise:        "key compromise",
+	ocsp.CACompromise:         "CA compromise",
+	ocsp.AffiliationChanged:   "affiliation changed",
+	ocsp.Superseded:           "superseded",
+	ocsp.CessationOfOperation: "cessation of operation",
+	ocsp.CertificateHold:      "certificate hold",
+	ocsp.PrivilegeWithdrawn:   "privilege withdrawn",
+	ocsp.AACompromise:         "AA compromise",
+}
+
+// ocspStatus returns the OCSP response of the first of the leaf's responders
+// that answers, or nil if the leaf names none. The issuer is the next
+// certificate of the chain, or downloaded from the leaf's issuer URL when
+// the server does not send it.
+func ocspStatus(ctx context.Context, client *http.Client, chain []*x509.Certificate) (*ocsp.Response, error) {
+	leaf := chain[0]
+	if len(leaf.OCSPServer) == 0 {
+		return nil, nil
+	}
+	issuer := chainIssuer(chain)
+	if issuer == nil {
+		var err error
+		if issuer, err = fetchIssuer(ctx, client, leaf); err != nil {
+			return nil, fmt.Errorf("issuer: %w", err)
+		}
+	}
+	req, err := ocsp.CreateRequest(leaf, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, server := range leaf.OCSPServer {
+		body, err := fetchURL(ctx, client, server, req)
+		if err == nil {
+			var resp *ocsp.Response
+			if resp, err = ocsp.ParseResponseForCert(body, leaf, issuer); err == nil {
+				return resp, nil
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetchIssuer downloads the issuer of cert from its issuer URLs, as DER or
+// PEM.
+func fetchIssuer(ctx context.Context, client *http.Client, cert *x509.Certificate) (*x509.Certificate, error) {
+	if len(cert.IssuingCertificateURL) == 0 {
+		return nil, errors.New("not in the chain and the certificate has no issuer URL")
+	}
+	var errs []error
+	for _, url := range cert.IssuingCertificateURL {
+		body, err := fetchURL(ctx, client, url, nil)
+		if err == nil {
+			if block, _ := pem.Decode(body); block != nil {
+				body = block.Bytes
+			}
+			var issuer *x509.Certificate
+			if issuer, err = x509.ParseCertificate(body); err == nil {
+				return issuer, nil
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", url, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetchURL gets url, or posts req to it as an OCSP request if it is set,
+// and returns the body of a 200 response.
+func fetchURL(ctx context.Context, client *http.Client, url string, req []byte) ([]byte, error) {
+	hreq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if req != nil {
+		hreq, err = http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(req))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		hreq.Header.Set("Content-Type", "application/ocsp-request")
+	}
+	resp, err := client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
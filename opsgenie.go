@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("opsgenie", func(decode func(any) error) (Notifier, error) {
+		n := &OpsgenieNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.APIKey == "" {
+			return nil, fmt.Errorf("opsgenie: api_key is required")
+		}
+		switch n.Region {
+		case "", "us", "eu":
+		default:
+			return nil, fmt.Errorf("opsgenie: unknown region %q", n.Region)
+		}
+		return n, nil
+	})
+}
+
+// OpsgenieNotifier opens one Opsgenie alert per problematic site. Alerts are
+// aliased by group and host:port so repeated runs deduplicate, and are
+// closed once the site checks out fine again or its certificate is renewed.
+type OpsgenieNotifier struct {
+	APIKey string   `toml:"api_key"`
+	Region string   `toml:"region"` // "us" (default) or "eu"
+	Teams  []string `toml:"teams"`
+	Tags   []string `toml:"tags"`
+}
+
+type opsgenieResponder struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type opsgenieAlert struct {
+	Message     string              `json:"message"`
+	Alias       string              `json:"alias,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Responders  []opsgenieResponder `json:"responders,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Priority    string              `json:"priority"`
+	Source      string              `json:"source"`
+}
+
+func (n *OpsgenieNotifier) baseURL() string {
+	if n.Region == "eu" {
+		return "https://api.eu.opsgenie.com/v2/alerts"
+	}
+	return "https://api.opsgenie.com/v2/alerts"
+}
+
+func opsgenieAlias(group, site string) string {
+	return "crtwtch-" + group + "-" + hostPort(site)
+}
+
+func opsgeniePriority(s Status) string {
+	switch s {
+	case StatusExpired, StatusRevoked:
+		return "P1"
+	case StatusFailed, StatusCritical, StatusUntrusted, StatusMismatch, StatusStaple, StatusCT, StatusUnexpectedIssuer, StatusPinMismatch, StatusTLSPolicy, StatusSelfSigned, StatusValidity, StatusDANE, StatusDrift:
+		return "P2"
+	}
+	return "P3"
+}
+
+// opsgenieMessage truncates msg to the 130 characters Opsgenie accepts.
+func opsgenieMessage(msg string) string {
+	msg, _, _ = strings.Cut(msg, "\n")
+	if r := []rune(msg); len(r) > 130 {
+		return string(r[:130])
+	}
+	return msg
+}
+
+func (n *OpsgenieNotifier) Send(msg string, level slog.Level) error {
+	priority := "P5"
+	switch {
+	case level >= slog.LevelError:
+		priority = "P2"
+	case level >= slog.LevelWarn:
+		priority = "P3"
+	}
+	return n.create(opsgenieAlert{Message: opsgenieMessage(msg), Description: msg, Priority: priority})
+}
+
+func (n *OpsgenieNotifier) SendReport(rep *Report) error {
+	var errs []error
+	for i := range rep.Results {
+		r := &rep.Results[i]
+		alias := opsgenieAlias(rep.Group, r.Site)
+		switch r.Status {
+		case StatusOK:
+			// only once on recovery, not for every healthy site every pass
+			if r.recovered {
+				errs = append(errs, n.close(alias, "certificate is valid again"))
+			}
+			continue
+		case StatusSkipped:
+			// unknown state, leave an open alert alone
+			continue
+		}
+		errs = append(errs, n.create(opsgenieAlert{
+			Message:     opsgenieMessage(fmt.Sprintf("[%s] %s", rep.Group, r.Message())),
+			Alias:       alias,
+			Description: r.Message(),
+			Priority:    opsgeniePriority(r.Status),
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// Resolve closes the alerts of sites whose certificate was renewed.
+func (n *OpsgenieNotifier) Resolve(group string, renewed []Result) error {
+	var errs []error
+	for i := range renewed {
+		note := "certificate renewed, valid until " + renewed[i].Expiry.Format("2006-01-02")
+		errs = append(errs, n.close(opsgenieAlias(group, renewed[i].Site), note))
+	}
+	return errors.Join(errs...)
+}
+
+func (n *OpsgenieNotifier) create(alert opsgenieAlert) error {
+	alert.Source = "crtwtch"
+	alert.Tags = n.Tags
+	for _, team := range n.Teams {
+		alert.Responders = append(alert.Responders, opsgenieResponder{Name: team, Type: "team"})
+	}
+	return n.post(n.baseURL(), alert)
+}
+
+func (n *OpsgenieNotifier) close(alias, note string) error {
+	u := n.baseURL() + "/" + url.PathEscape(alias) + "/close?identifierType=alias"
+	return n.post(u, map[string]string{"source": "crtwtch", "note": note})
+}
+
+func (n *OpsgenieNotifier) post(u string, payload any) error {
+	header := map[string]string{"Authorization": "GenieKey " + n.APIKey}
+	status, body, err := sendJSON("POST", u, header, payload)
+	if err != nil {
+		return fmt.Errorf("opsgenie: %w", err)
+	}
+	// closing an alias that was never opened is answered with 404
+	if status == 404 && strings.HasSuffix(u, "identifierType=alias") {
+		return nil
+	}
+	if status != 202 && status != 200 {
+		return fmt.Errorf("opsgenie: unexpected status code %d: %s", status, body)
+	}
+	return nil
+}
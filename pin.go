@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// pin is an expected public key or certificate of a site's chain.
+type pin struct {
+	spki bool // hash of the SubjectPublicKeyInfo, else of the whole certificate
+	hash []byte
+}
+
+// parsePin reads a pin either as "sha256/<base64>", the SHA-256 of a public
+// key as curl's --pinnedpubkey and HPKP write it, or as the hex SHA-256
+// fingerprint of a certificate, with or without colons.
+func parsePin(s string) (pin, error) {
+	if b64, ok := strings.CutPrefix(s, "sha256/"); ok {
+		hash, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || len(hash) != sha256.Size {
+			return pin{}, fmt.Errorf("bad public key pin %q", s)
+		}
+		return pin{spki: true, hash: hash}, nil
+	}
+	hash, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(hash) != sha256.Size {
+		return pin{}, fmt.Errorf("bad pin %q, want sha256/<base64> or a SHA-256 fingerprint", s)
+	}
+	return pin{hash: hash}, nil
+}
+
+func (p pin) matches(cert *x509.Certificate) bool {
+	data := cert.Raw
+	if p.spki {
+		data = cert.RawSubjectPublicKeyInfo
+	}
+	sum := sha256.Sum256(data)
+	return bytes.Equal(sum[:], p.hash)
+}
+
+// spkiPin returns the public key pin of cert.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkPins marks r when none of the site's pins matches a certificate of
+// chain, so a CA or intermediate can be pinned as well as the leaf. The
+// reason names the leaf's public key pin to make updating the pins easy.
+func checkPins(r *Result, site *Site, chain []*x509.Certificate) {
+	if len(site.Pins) == 0 {
+		return
+	}
+	for _, s := range site.Pins {
+		p, _ := parsePin(s) // validated when the config was read
+		for _, cert := range chain {
+			if p.matches(cert) {
+				return
+			}
+		}
+	}
+	r.Status, r.Reason = StatusPinMismatch, "no pin matches, the leaf key is "+spkiPin(chain[0])
+	slog.Warn("certificate does not match the pins", "site", r.Site, "leaf", spkiPin(chain[0]))
+}
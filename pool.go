@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultConcurrency = 16
+
+// checkAll checks the group's sites on a bounded pool of workers. Results
+// keep the order of the sites list no matter which check finishes first.
+// Besides the group's own limit, every check holds one of the config-wide
+// slots so groups running side by side in daemon mode share the budget.
+// Sites still queued when ctx is done are reported as skipped.
+func (group *WatchGroup) checkAll(ctx context.Context, check func(site *Site) []Result) []Result {
+	results := make([][]Result, len(group.Sites))
+	workers := group.Concurrency
+	if workers <= 0 {
+		workers = group.config.Concurrency
+	}
+	workers = min(workers, len(group.Sites))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range jobs {
+				site := &group.Sites[i]
+				if !group.config.acquire(ctx) {
+					results[i] = []Result{group.skipped(site)}
+					continue
+				}
+				start := time.Now()
+				results[i] = check(site)
+				group.config.release()
+				took := time.Since(start)
+				for j := range results[i] {
+					results[i][j].Duration = took
+				}
+			}
+		})
+	}
+	for i := range group.Sites {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return slices.Concat(results...)
+}
+
+// acquire takes one of the config-wide check slots. It fails once ctx is
+// done, even if a slot happened to be free.
+func (config *Config) acquire(ctx context.Context) bool {
+	select {
+	case config.slots <- struct{}{}:
+		if ctx.Err() != nil {
+			config.release()
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (config *Config) release() { <-config.slots }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// protocol is how the TLS handshake of a site is reached, or its
+// certificate read otherwise. Sites name theirs with a scheme, as in
+// "smtp://mail.example.com:587"; a bare "host[:port]" is HTTPS.
+type protocol struct {
+	port string   // default port
+	alpn []string // application protocols to offer in the handshake
+	// read, if set, gets the certificate from the path after the scheme
+	// instead of from a server, with the site's password if it has one.
+	read func(path, password string) ([]*x509.Certificate, error)
+	// upgrade, if set, has the server switch the plain connection to TLS,
+	// such as with STARTTLS. host is the server name the check is for.
+	upgrade func(conn net.Conn, host string) error
+	// handshake, if set, does the TLS handshake over a transport of its own
+	// instead of TCP, dialing addr over the first of networks that works.
+	handshake func(ctx context.Context, addr string, config *tls.Config, networks []string) (tls.ConnectionState, error)
+}
+
+// protocols maps site schemes to their protocol. Protocols that need an
+// upgrade add themselves from init in their own file.
+var protocols = map[string]protocol{
+	"https": {port: "443"},
+	"tls":   {port: "443"},
+}
+
+// siteTarget splits a site address into its protocol and the host:port to
+// dial, adding the protocol's default port when the address has none. For
+// protocols that read the certificate the rest of the address is the path.
+func siteTarget(site string) (protocol, string, error) {
+	scheme, addr, ok := strings.Cut(site, "://")
+	if !ok {
+		scheme, addr = "https", site
+	}
+	proto, ok := protocols[strings.ToLower(scheme)]
+	if !ok {
+		return protocol{}, "", fmt.Errorf("unknown protocol %q", scheme)
+	}
+	if proto.read != nil {
+		return proto, addr, nil
+	}
+	return proto, withPort(strings.TrimSuffix(addr, "/"), proto.port), nil
+}
+
+// withPort returns addr with port added when it has none. An IPv6 literal
+// without a port may be written with or without brackets.
+func withPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"), port)
+}
@@ -0,0 +1,7 @@
+package main
+
+func init() {
+	// DNS over TLS (RFC 7858) is implicit TLS; resolvers may insist on its
+	// ALPN ID
+	protocols["dot"] = protocol{port: "853", alpn: []string{"dot"}}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func init() {
+	protocols["file"] = protocol{read: readCertFile}
+}
+
+var errNoCerts = errors.New("no certificate found")
+
+// readCertFile reads the certificate chain of a PEM, PKCS#12 (.p12, .pfx)
+// or Java keystore (.jks, .keystore) file, told apart by the file extension.
+func readCertFile(path, password string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".p12", ".pfx":
+		return readPKCS12(data, password)
+	case ".jks", ".keystore":
+		certs, err := readJKS(data, password)
+		if err != nil {
+			return nil, err
+		}
+		// the entries are not a chain; report the one expiring first
+		return []*x509.Certificate{firstExpiring(certs)}, nil
+	}
+	return readPEM(data)
+}
+
+// readPEM returns the certificates of PEM data in order, which in the usual
+// full chain files is the leaf first.
+func readPEM(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errNoCerts
+	}
+	return certs, nil
+}
+
+// readPKCS12 returns the chain of the key in a PKCS#12 bundle, or for a
+// trust store without a key the certificate that expires first.
+func readPKCS12(data []byte, password string) ([]*x509.Certificate, error) {
+	_, cert, cas, err := pkcs12.DecodeChain(data, password)
+	if err == nil {
+		return append([]*x509.Certificate{cert}, cas...), nil
+	}
+	certs, terr := pkcs12.DecodeTrustStore(data, password)
+	if terr != nil || len(certs) == 0 {
+		return nil, err // the bundle's own error is the more telling one
+	}
+	return []*x509.Certificate{firstExpiring(certs)}, nil
+}
+
+// firstExpiring returns the certificate of certs, which is not empty, that
+// expires first.
+func firstExpiring(certs []*x509.Certificate) *x509.Certificate {
+	return slices.MinFunc(certs, func(a, b *x509.Certificate) int { return a.NotAfter.Compare(b.NotAfter) })
+}
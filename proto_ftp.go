@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+)
+
+func init() {
+	protocols["ftp"] = protocol{port: "21", upgrade: ftpAuthTLS}
+	protocols["ftps"] = protocol{port: "990"}
+}
+
+// ftpAuthTLS issues AUTH TLS (RFC 4217) after the server greeting.
+func ftpAuthTLS(conn net.Conn, host string) error {
+	tp := textproto.NewConn(conn)
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("ftp greeting: %w", err)
+	}
+	if err := tp.PrintfLine("AUTH TLS"); err != nil {
+		return err
+	}
+	if _, _, err := tp.ReadResponse(234); err != nil {
+		return fmt.Errorf("ftp AUTH TLS: %w", err)
+	}
+	return nil
+}
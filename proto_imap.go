@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+func init() {
+	protocols["imap"] = protocol{port: "143", upgrade: imapStartTLS}
+	protocols["imaps"] = protocol{port: "993"}
+}
+
+// imapStartTLS issues STARTTLS after the server greeting.
+func imapStartTLS(conn net.Conn, host string) error {
+	tp := textproto.NewConn(conn)
+	greeting, err := tp.ReadLine()
+	if err != nil {
+		return fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("imap greeting: %q", greeting)
+	}
+	if err := tp.PrintfLine("a1 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return fmt.Errorf("imap STARTTLS: %w", err)
+		}
+		if strings.HasPrefix(line, "* ") {
+			continue // untagged data, such as capabilities
+		}
+		if !strings.HasPrefix(line, "a1 OK") {
+			return fmt.Errorf("imap STARTTLS: %q", line)
+		}
+		return nil
+	}
+}
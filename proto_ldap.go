@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+func init() {
+	protocols["ldap"] = protocol{port: "389", upgrade: ldapStartTLS}
+	protocols["ldaps"] = protocol{port: "636"}
+}
+
+// ldapStartTLSRequest is the StartTLS extended request (RFC 4511 4.14) as
+// message 1: SEQUENCE { 1, [APPLICATION 23] { [0] "1.3.6.1.4.1.1466.20037" } }.
+var ldapStartTLSRequest = append([]byte{0x30, 0x1d, 0x02, 0x01, 0x01, 0x77, 0x18, 0x80, 0x16}, "1.3.6.1.4.1.1466.20037"...)
+
+// ldapStartTLS sends the StartTLS extended operation and checks that the
+// server accepted it. The response is decoded by hand: encoding/asn1 only
+// takes DER and rejects the long length forms Active Directory sends.
+func ldapStartTLS(conn net.Conn, host string) error {
+	if _, err := conn.Write(ldapStartTLSRequest); err != nil {
+		return err
+	}
+	data, err := readBER(conn)
+	if err != nil {
+		return fmt.Errorf("ldap StartTLS: %w", err)
+	}
+	next := func(data *[]byte, tag byte) []byte {
+		if err != nil {
+			return nil
+		}
+		var t byte
+		var content []byte
+		t, content, *data, err = splitBER(*data)
+		if err == nil && t != tag {
+			err = fmt.Errorf("unexpected tag %#x, want %#x", t, tag)
+		}
+		return content
+	}
+	msg := next(&data, 0x30)
+	next(&msg, 0x02)       // messageID
+	op := next(&msg, 0x78) // ExtendedResponse
+	code := next(&op, 0x0a)
+	next(&op, 0x04) // matchedDN
+	diag := next(&op, 0x04)
+	if err != nil {
+		return fmt.Errorf("ldap StartTLS response: %w", err)
+	}
+	if len(code) != 1 || code[0] != 0 {
+		return fmt.Errorf("ldap StartTLS refused: result code %x: %s", code, diag)
+	}
+	return nil
+}
+
+var errBER = errors.New("malformed BER")
+
+// berLength decodes the length octets at the start of b and returns the
+// length and how many octets it took. Only definite lengths are supported.
+func berLength(b []byte) (n, size int, err error) {
+	if len(b) == 0 {
+		return 0, 0, errBER
+	}
+	if b[0]&0x80 == 0 {
+		return int(b[0]), 1, nil
+	}
+	size = int(b[0] & 0x7f)
+	if size == 0 || size > 4 || len(b) < 1+size {
+		return 0, 0, errBER
+	}
+	for _, c := range b[1 : 1+size] {
+		n = n<<8 | int(c)
+	}
+	return n, 1 + size, nil
+}
+
+// readBER reads exactly one BER element from r, so nothing that follows it
+// on the connection is consumed.
+func readBER(r io.Reader) ([]byte, error) {
+	head := make([]byte, 2, 6)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if extra := int(head[1] & 0x7f); head[1]&0x80 != 0 && extra <= 4 {
+		head = head[:2+extra]
+		if _, err := io.ReadFull(r, head[2:]); err != nil {
+			return nil, err
+		}
+	}
+	n, _, err := berLength(head[1:])
+	if err != nil {
+		return nil, err
+	}
+	if n > 1<<16 {
+		return nil, fmt.Errorf("BER element of %d bytes is too long", n)
+	}
+	body := make([]byte, len(head)+n)
+	copy(body, head)
+	_, err = io.ReadFull(r, body[len(head):])
+	return body, err
+}
+
+// splitBER splits the first BER element off data into its tag and content.
+func splitBER(data []byte) (tag byte, content, rest []byte, err error) {
+	if len(data) < 2 {
+		return 0, nil, nil, errBER
+	}
+	n, size, err := berLength(data[1:])
+	if err != nil || len(data) < 1+size+n {
+		return 0, nil, nil, errBER
+	}
+	start := 1 + size
+	return data[0], data[start : start+n], data[start+n:], nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+func init() {
+	protocols["mysql"] = protocol{port: "3306", upgrade: mysqlSSLRequest}
+}
+
+const (
+	mysqlClientLongPassword     = 0x1
+	mysqlClientProtocol41       = 0x200
+	mysqlClientSSL              = 0x800
+	mysqlClientSecureConnection = 0x8000
+	mysqlClientPluginAuth       = 0x80000
+)
+
+// mysqlSSLRequest reads the server's initial handshake packet and answers
+// with an SSLRequest packet, after which MySQL and MariaDB expect the TLS
+// handshake.
+func mysqlSSLRequest(conn net.Conn, host string) error {
+	var head [4]byte // 3 byte little endian payload length, sequence number
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return fmt.Errorf("mysql handshake: %w", err)
+	}
+	n := int(head[0]) | int(head[1])<<8 | int(head[2])<<16
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("mysql handshake: %w", err)
+	}
+	if len(payload) > 3 && payload[0] == 0xff {
+		// e.g. the host is not allowed to connect
+		return fmt.Errorf("mysql server error %d: %s", binary.LittleEndian.Uint16(payload[1:]), payload[3:])
+	}
+	if len(payload) == 0 || payload[0] != 10 {
+		return fmt.Errorf("mysql handshake: unsupported protocol version")
+	}
+	// version string, connection id, 8 bytes of auth data and a filler,
+	// then the lower half of the capability flags
+	end := bytes.IndexByte(payload[1:], 0)
+	off := 1 + end + 1 + 4 + 8 + 1
+	if end < 0 || len(payload) < off+2 {
+		return fmt.Errorf("mysql handshake: packet too short")
+	}
+	if binary.LittleEndian.Uint16(payload[off:])&mysqlClientSSL == 0 {
+		return fmt.Errorf("mysql server does not support SSL")
+	}
+	req := make([]byte, 4+32)
+	req[0], req[3] = 32, head[3]+1
+	flags := uint32(mysqlClientLongPassword | mysqlClientProtocol41 | mysqlClientSSL | mysqlClientSecureConnection | mysqlClientPluginAuth)
+	binary.LittleEndian.PutUint32(req[4:], flags)
+	binary.LittleEndian.PutUint32(req[8:], 1<<24) // max packet size
+	req[12] = 33                                  // utf8_general_ci
+	_, err := conn.Write(req)
+	return err
+}
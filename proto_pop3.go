@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+func init() {
+	protocols["pop3"] = protocol{port: "110", upgrade: pop3StartTLS}
+	protocols["pop3s"] = protocol{port: "995"}
+}
+
+// pop3StartTLS issues STLS (RFC 2595) after the server greeting.
+func pop3StartTLS(conn net.Conn, host string) error {
+	tp := textproto.NewConn(conn)
+	if err := pop3OK(tp, "greeting"); err != nil {
+		return err
+	}
+	if err := tp.PrintfLine("STLS"); err != nil {
+		return err
+	}
+	return pop3OK(tp, "STLS")
+}
+
+// pop3OK reads one reply and fails unless it is +OK.
+func pop3OK(tp *textproto.Conn, what string) error {
+	line, err := tp.ReadLine()
+	if err != nil {
+		return fmt.Errorf("pop3 %s: %w", what, err)
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("pop3 %s: %q", what, line)
+	}
+	return nil
+}
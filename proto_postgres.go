@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+func init() {
+	p := protocol{port: "5432", upgrade: postgresSSLRequest}
+	protocols["postgres"] = p
+	protocols["postgresql"] = p
+}
+
+// postgresSSLRequest sends the SSLRequest startup message: its length, 8,
+// and the code 80877103. The server answers with a single byte.
+func postgresSSLRequest(conn net.Conn, host string) error {
+	if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+		return err
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return fmt.Errorf("postgres SSLRequest: %w", err)
+	}
+	switch answer[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return fmt.Errorf("postgres server does not accept SSL")
+	}
+	return fmt.Errorf("postgres SSLRequest: unexpected answer %q", answer)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/quic-go/quic-go"
+)
+
+func init() {
+	// QUIC servers refuse clients without a protocol they speak
+	protocols["quic"] = protocol{port: "443", alpn: []string{"h3"}, handshake: quicHandshake}
+}
+
+// quicHandshake does the TLS handshake of a QUIC connection (RFC 9001) over
+// UDP. The connection is closed once the handshake is done.
+func quicHandshake(ctx context.Context, addr string, config *tls.Config, networks []string) (tls.ConnectionState, error) {
+	config = config.Clone()
+	config.MinVersion = tls.VersionTLS13 // all QUIC has
+	var errs []error
+	for _, network := range networks {
+		network = strings.Replace(network, "tcp", "udp", 1)
+		state, err := quicDial(ctx, network, addr, config)
+		if err == nil {
+			return state, nil
+		}
+		errs = append(errs, err)
+	}
+	return tls.ConnectionState{}, errors.Join(errs...)
+}
+
+func quicDial(ctx context.Context, network, addr string, config *tls.Config) (tls.ConnectionState, error) {
+	raddr, err := net.ResolveUDPAddr(network, addr)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	pconn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer pconn.Close()
+	conn, err := quic.Dial(ctx, pconn, raddr, config, nil)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.CloseWithError(0, "")
+	return conn.ConnectionState().TLS, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+func init() {
+	protocols["rdp"] = protocol{port: "3389", upgrade: rdpNegotiate}
+}
+
+// rdpConnectionRequest is a TPKT framed X.224 Connection Request carrying
+// an RDP Negotiation Request for TLS or CredSSP, both of which start with a
+// TLS handshake ([MS-RDPBCGR] 2.2.1.1).
+var rdpConnectionRequest = []byte{
+	0x03, 0x00, 0x00, 0x13, // TPKT version 3, length 19
+	0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, // X.224 CR TPDU
+	0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00, // RDP_NEG_REQ, PROTOCOL_SSL | PROTOCOL_HYBRID
+}
+
+var rdpFailures = map[uint32]string{
+	1: "TLS required by the server",
+	2: "TLS not allowed by the server",
+	3: "no certificate on the server",
+	4: "inconsistent flags",
+	5: "CredSSP required by the server",
+	6: "CredSSP with early user authorization required by the server",
+}
+
+// rdpNegotiate asks the server for a TLS based security protocol and checks
+// that it picked one.
+func rdpNegotiate(conn net.Conn, host string) error {
+	if _, err := conn.Write(rdpConnectionRequest); err != nil {
+		return err
+	}
+	var tpkt [4]byte
+	if _, err := io.ReadFull(conn, tpkt[:]); err != nil {
+		return fmt.Errorf("rdp connection confirm: %w", err)
+	}
+	n := int(binary.BigEndian.Uint16(tpkt[2:]))
+	if tpkt[0] != 3 || n < 4+7 {
+		return fmt.Errorf("rdp connection confirm: not a TPKT packet")
+	}
+	resp := make([]byte, n-4)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return fmt.Errorf("rdp connection confirm: %w", err)
+	}
+	// X.224 Connection Confirm, then RDP_NEG_RSP or RDP_NEG_FAILURE
+	neg := resp[7:]
+	if len(neg) < 8 {
+		return fmt.Errorf("rdp server does not support TLS")
+	}
+	value := binary.LittleEndian.Uint32(neg[4:])
+	switch neg[0] {
+	case 0x02:
+		if value == 0 {
+			return fmt.Errorf("rdp server chose standard RDP security, which has no TLS")
+		}
+		return nil
+	case 0x03:
+		if why, ok := rdpFailures[value]; ok {
+			return fmt.Errorf("rdp negotiation failed: %s", why)
+		}
+		return fmt.Errorf("rdp negotiation failed: code %d", value)
+	}
+	return fmt.Errorf("rdp connection confirm: unexpected negotiation type %#x", neg[0])
+}
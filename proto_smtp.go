@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+func init() {
+	protocols["smtp"] = protocol{port: "25", upgrade: smtpStartTLS}
+	protocols["smtps"] = protocol{port: "465"}
+}
+
+// smtpStartTLS greets the server and issues STARTTLS, as mail servers on
+// ports 25 and 587 expect before the handshake.
+func smtpStartTLS(conn net.Conn, host string) error {
+	tp := textproto.NewConn(conn)
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	// an address literal is an EHLO argument every server has to accept
+	ip, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+	if err := tp.PrintfLine("EHLO %s", smtpAddressLiteral(ip)); err != nil {
+		return err
+	}
+	_, ext, err := tp.ReadResponse(250)
+	if err != nil {
+		return fmt.Errorf("smtp EHLO: %w", err)
+	}
+	found := false
+	for _, line := range strings.Split(ext, "\n") {
+		found = found || strings.EqualFold(strings.TrimSpace(line), "STARTTLS")
+	}
+	if !found {
+		return fmt.Errorf("smtp server does not offer STARTTLS")
+	}
+	if err := tp.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("smtp STARTTLS: %w", err)
+	}
+	return nil
+}
+
+// smtpAddressLiteral writes ip as an address literal of RFC 5321, which
+// tags IPv6 addresses: [192.0.2.1], [IPv6:2001:db8::1].
+func smtpAddressLiteral(ip string) string {
+	ip, _, _ = strings.Cut(ip, "%") // a literal has no zone
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "[" + ip + "]"
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return "[" + v4.String() + "]"
+	}
+	return "[IPv6:" + parsed.String() + "]"
+}
@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestSMTPAddressLiteral(t *testing.T) {
+	for _, tt := range []struct {
+		ip, want string
+	}{
+		{"192.0.2.1", "[192.0.2.1]"},
+		{"2001:db8::1", "[IPv6:2001:db8::1]"},
+		{"fe80::1%eth0", "[IPv6:fe80::1]"},
+		{"::ffff:192.0.2.1", "[192.0.2.1]"},
+	} {
+		if got := smtpAddressLiteral(tt.ip); got != tt.want {
+			t.Errorf("smtpAddressLiteral(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
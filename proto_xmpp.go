@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+)
+
+func init() {
+	protocols["xmpp"] = protocol{port: "5222", upgrade: xmppStartTLS("jabber:client")}
+	protocols["xmpp-server"] = protocol{port: "5269", upgrade: xmppStartTLS("jabber:server")}
+}
+
+const xmppTLSNamespace = "urn:ietf:params:xml:ns:xmpp-tls"
+
+// xmppStartTLS opens a client or server-to-server stream to host, which
+// has to be the XMPP domain, and negotiates STARTTLS (RFC 6120 5).
+func xmppStartTLS(ns string) func(conn net.Conn, host string) error {
+	return func(conn net.Conn, host string) error {
+		var to strings.Builder
+		xml.EscapeText(&to, []byte(host))
+		header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='%s' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", to.String(), ns)
+		if _, err := conn.Write([]byte(header)); err != nil {
+			return err
+		}
+		dec := xml.NewDecoder(conn)
+		offered, err := xmppOffersTLS(dec)
+		if err != nil {
+			return fmt.Errorf("xmpp stream features: %w", err)
+		}
+		if !offered {
+			return fmt.Errorf("xmpp server does not offer STARTTLS")
+		}
+		if _, err := conn.Write([]byte("<starttls xmlns='" + xmppTLSNamespace + "'/>")); err != nil {
+			return err
+		}
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("xmpp STARTTLS: %w", err)
+			}
+			if t, ok := tok.(xml.StartElement); ok {
+				if t.Name.Local != "proceed" {
+					return fmt.Errorf("xmpp STARTTLS: server answered %s", t.Name.Local)
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// xmppOffersTLS reads the server's stream header and features, and reports
+// whether the features include STARTTLS.
+func xmppOffersTLS(dec *xml.Decoder) (bool, error) {
+	offered := false
+	for depth := 0; ; {
+		tok, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && t.Name.Local == "error" {
+				return false, fmt.Errorf("stream error")
+			}
+			offered = offered || t.Name.Space == xmppTLSNamespace && t.Name.Local == "starttls"
+		case xml.EndElement:
+			depth--
+			if t.Name.Local == "features" {
+				return offered, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// parseProxy reads a proxy setting: an "http://" proxy taking CONNECT or a
+// "socks5://" one, either with optional "user:password@", or "direct" for
+// none, which lets a group opt out of the global proxy. Empty returns nil.
+func parseProxy(s string) (*url.URL, error) {
+	if s == "" || s == "direct" {
+		return nil, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	switch {
+	case u.Scheme != "http" && u.Scheme != "socks5" && u.Scheme != "socks5h":
+		return nil, fmt.Errorf("proxy %s: want an http:// or socks5:// URL", u.Redacted())
+	case u.Host == "":
+		return nil, fmt.Errorf("proxy %s: no host", u.Redacted())
+	}
+	return u, nil
+}
+
+// proxyURL returns the proxy the group's checks go through, nil for none.
+func (group *WatchGroup) proxyURL() *url.URL {
+	if group.Proxy != "" {
+		return group.proxy
+	}
+	return group.config.proxy
+}
+
+// httpClient returns a client for the HTTP requests of checks, such as OCSP
+// queries, that goes through the group's proxy or jump host if it has one.
+func (group *WatchGroup) httpClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if u, jump := group.proxyURL(), group.sshJump(); u != nil || jump != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		if jump != nil {
+			t.Proxy = nil
+			t.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return jump.dial(ctx, addr, timeout)
+			}
+		} else {
+			t.Proxy = http.ProxyURL(u)
+		}
+		client.Transport = t
+	}
+	return client
+}
+
+// dialProxy connects to addr through the proxy u. The proxy resolves the
+// name, so the site's family does not apply.
+func dialProxy(ctx context.Context, u *url.URL, addr string) (net.Conn, error) {
+	port := "1080"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", withPort(u.Host, port))
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+	tunnel := conn
+	if u.Scheme == "http" {
+		tunnel, err = connectHTTP(conn, u, addr)
+	} else {
+		err = connectSOCKS5(conn, u, addr)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// connectHTTP asks an HTTP proxy for a tunnel to addr with CONNECT.
+func connectHTTP(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: addr}, Host: addr, Header: make(http.Header)}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	// servers of protocols such as SMTP speak first, and what they said may
+	// already be buffered
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// connectSOCKS5 asks a SOCKS5 proxy (RFC 1928) for a connection to addr,
+// logging in with the user and password of u (RFC 1929) if it has them.
+func connectSOCKS5(conn net.Conn, u *url.URL, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("bad port %q", portStr)
+	}
+	methods := []byte{0} // no authentication
+	if u.User != nil {
+		methods = []byte{2} // username and password
+	}
+	if _, err := conn.Write(append([]byte{5, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 5 || reply[1] != methods[0] {
+		return errors.New("SOCKS5 authentication method refused")
+	}
+	if u.User != nil {
+		user, password := u.User.Username(), ""
+		password, _ = u.User.Password()
+		msg := append([]byte{1, byte(len(user))}, user...)
+		msg = append(append(msg, byte(len(password))), password...)
+		if _, err := conn.Write(msg); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("SOCKS5 login failed")
+		}
+	}
+	req := []byte{5, 1, 0} // CONNECT
+	if ip := net.ParseIP(host); ip.To4() != nil {
+		req = append(append(req, 1), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 4), ip...)
+	} else {
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("SOCKS5 CONNECT %s failed with code %d", addr, head[1])
+	}
+	// skip the bound address, which tells nothing useful
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len
+	case 4:
+		skip = net.IPv6len
+	case 3:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("bad SOCKS5 address type %d", head[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
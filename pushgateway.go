@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Pushgateway is a Prometheus Pushgateway the metrics of each pass are
+// pushed to, for cron runs that are gone before any scrape. Each group is
+// pushed under its own grouping key of job, instance and group, replacing
+// what its last pass pushed.
+type Pushgateway struct {
+	URL      string `toml:"url"`      // such as "http://pushgateway:9091"
+	Job      string `toml:"job"`      // default "crtwtch"
+	Instance string `toml:"instance"` // default the host name
+}
+
+// push sends the metrics of group's last pass. A failed push is only
+// logged, like a failed heartbeat.
+func (p *Pushgateway) push(group string) {
+	if p == nil || p.URL == "" {
+		return
+	}
+	var body strings.Builder
+	if err := metrics.writeGroups(&body, []string{group}); err != nil {
+		slog.Error("failed to push metrics", "group", group, "error", err)
+		return
+	}
+	status, resp, err := doHTTP("PUT", p.groupingURL(group), map[string]string{"Content-Type": "text/plain; version=0.0.4"}, body.String())
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("unexpected status code: %d: %s", status, strings.TrimSpace(string(resp)))
+	}
+	if err != nil {
+		slog.Error("failed to push metrics", "group", group, "error", err)
+		return
+	}
+	slog.Info("metrics pushed", "group", group)
+}
+
+// groupingURL is the URL of the grouping key of group.
+func (p *Pushgateway) groupingURL(group string) string {
+	job, instance := p.Job, p.Instance
+	if job == "" {
+		job = "crtwtch"
+	}
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	u := strings.TrimSuffix(p.URL, "/") + "/metrics/" + groupingPath("job", job)
+	if instance != "" {
+		u += "/" + groupingPath("instance", instance)
+	}
+	return u + "/" + groupingPath("group", group)
+}
+
+// groupingPath is one label of a grouping key as a path, base64-encoded when
+// the value has a slash or is empty, which a plain path cannot carry.
+func groupingPath(label, value string) string {
+	if value == "" {
+		return label + "@base64/="
+	}
+	if strings.Contains(value, "/") {
+		return label + "@base64/" + base64.RawURLEncoding.EncodeToString([]byte(value))
+	}
+	return label + "/" + url.PathEscape(value)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+)
+
+func init() {
+	RegisterNotifier("pushover", func(decode func(any) error) (Notifier, error) {
+		n := &PushoverNotifier{Retry: 300, Expire: 3600}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.UserKey == "" || n.AppToken == "" {
+			return nil, fmt.Errorf("pushover: user_key and app_token are required")
+		}
+		if n.Retry < 30 || n.Expire <= 0 || n.Expire > 10800 {
+			return nil, fmt.Errorf("pushover: retry must be at least 30 and expire at most 10800 seconds")
+		}
+		return n, nil
+	})
+}
+
+// PushoverNotifier sends Pushover messages. Reports containing expired
+// certificates are sent with emergency priority, which repeats every Retry
+// seconds until acknowledged or Expire seconds have passed.
+type PushoverNotifier struct {
+	UserKey  string `toml:"user_key"`
+	AppToken string `toml:"app_token"`
+	Retry    int    `toml:"retry"`
+	Expire   int    `toml:"expire"`
+}
+
+const (
+	pushoverLow       = -1
+	pushoverNormal    = 0
+	pushoverHigh      = 1
+	pushoverEmergency = 2
+)
+
+func pushoverPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return pushoverHigh
+	case level >= slog.LevelWarn:
+		return pushoverNormal
+	}
+	return pushoverLow
+}
+
+func (n *PushoverNotifier) Send(msg string, level slog.Level) error {
+	return n.post(msg, pushoverPriority(level))
+}
+
+func (n *PushoverNotifier) SendReport(rep *Report) error {
+	priority := pushoverPriority(rep.Level())
+	for _, r := range rep.Results {
+		if r.Status == StatusExpired {
+			priority = pushoverEmergency
+			break
+		}
+	}
+	return n.post(rep.Text(), priority)
+}
+
+func (n *PushoverNotifier) post(msg string, priority int) error {
+	title, body := splitMessage(msg)
+	if r := []rune(body); len(r) > 1024 {
+		body = string(r[:1021]) + "..."
+	}
+	form := url.Values{
+		"token":    {n.AppToken},
+		"user":     {n.UserKey},
+		"title":    {title},
+		"message":  {body},
+		"priority": {strconv.Itoa(priority)},
+	}
+	if priority == pushoverEmergency {
+		form.Set("retry", strconv.Itoa(n.Retry))
+		form.Set("expire", strconv.Itoa(n.Expire))
+	}
+	header := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	status, resp, err := doHTTP("POST", "https://api.pushover.net/1/messages.json", header, form.Encode())
+	if err != nil {
+		return fmt.Errorf("pushover: %w", err)
+	}
+	if status != 200 {
+		return fmt.Errorf("pushover: unexpected status code %d: %s", status, resp)
+	}
+	return nil
+}
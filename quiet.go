@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// QuietHours is a daily window, such as 23:00 to 08:00, during which reports
+// below critical are held back and delivered when it ends. Reports about
+// expired or failing certificates still go out at once.
+type QuietHours struct {
+	From     string `toml:"from"` // "15:04"
+	To       string `toml:"to"`
+	Timezone string `toml:"timezone"` // IANA name, default the group's timezone
+
+	from, to time.Duration // since midnight
+	loc      *time.Location
+}
+
+func (q *QuietHours) enabled() bool { return q.From != "" || q.To != "" }
+
+func (q *QuietHours) setup(def *time.Location) error {
+	if !q.enabled() {
+		return nil
+	}
+	for _, f := range []struct {
+		s string
+		d *time.Duration
+	}{{q.From, &q.from}, {q.To, &q.to}} {
+		t, err := time.Parse("15:04", f.s)
+		if err != nil {
+			return fmt.Errorf("quiet_hours: bad time %q, want HH:MM", f.s)
+		}
+		*f.d = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	loc, err := loadLocation(q.Timezone, def)
+	if err != nil {
+		return fmt.Errorf("quiet_hours: %w", err)
+	}
+	q.loc = loc
+	return nil
+}
+
+// end returns when the quiet window containing t ends, or false when t is
+// outside quiet hours. Windows may span midnight.
+func (q *QuietHours) end(t time.Time) (time.Time, bool) {
+	if !q.enabled() || q.from == q.to {
+		return time.Time{}, false
+	}
+	t = t.In(q.loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, q.loc)
+	since := t.Sub(midnight)
+	switch {
+	case q.from < q.to && since >= q.from && since < q.to:
+		return midnight.Add(q.to), true
+	case q.from > q.to && since >= q.from:
+		return midnight.AddDate(0, 0, 1).Add(q.to), true
+	case q.from > q.to && since < q.to:
+		return midnight.Add(q.to), true
+	}
+	return time.Time{}, false
+}
+
+// heldReport is the latest report held back by quiet hours. A newer one
+// replaces it, since it describes the same sites more recently.
+type heldReport struct {
+	mu  sync.Mutex
+	rep *Report
+}
+
+// holdQuiet holds rep back if the group is in quiet hours and rep is not
+// critical, and reports whether it did. The daemon delivers it when the
+// window ends; a one-shot run records nothing, so the next run after the
+// window sends the alerts again.
+func (group *WatchGroup) holdQuiet(rep *Report) bool {
+	group.held.mu.Lock()
+	defer group.held.mu.Unlock()
+	end, quiet := group.QuietHours.end(time.Now())
+	if !quiet || rep.Level() >= slog.LevelError {
+		group.held.rep = nil // rep is sent now and supersedes it
+		return false
+	}
+	slog.Info("quiet hours, holding the report back", "group", group.Name, "until", end.Format(time.DateTime))
+	if !group.config.daemon {
+		return true
+	}
+	if group.held.rep == nil {
+		time.AfterFunc(time.Until(end), group.deliverHeld)
+	}
+	group.held.rep = rep
+	return true
+}
+
+func (group *WatchGroup) deliverHeld() {
+	group.held.mu.Lock()
+	rep := group.held.rep
+	group.held.rep = nil
+	group.held.mu.Unlock()
+	if rep == nil {
+		return
+	}
+	slog.Info("quiet hours over, sending the held report", "group", group.Name)
+	if err := group.notify(rep); err == nil {
+		group.recordState(rep)
+	}
+}
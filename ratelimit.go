@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// defaultRateLimits are the documented per-minute limits of chat robots.
+var defaultRateLimits = map[string]int{
+	"wxwork":   20,
+	"dingtalk": 20,
+	"feishu":   100,
+}
+
+// rateLimiter is a token bucket refilled at rate tokens per second. Callers
+// queue instead of being dropped: wait reserves a token, possibly in the
+// future, and sleeps until it is due.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(perMinute),
+		tokens: float64(perMinute),
+		last:   time.Now(),
+	}
+}
+
+func (l *rateLimiter) wait() time.Duration {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(d)
+	return d
+}
+
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = map[string]*rateLimiter{}
+)
+
+// setupRateLimits installs one limiter per notifier type, shared by every
+// group. A limit of 0 or less disables limiting for that type.
+func setupRateLimits(limits map[string]int) {
+	merged := make(map[string]int, len(defaultRateLimits))
+	for typ, n := range defaultRateLimits {
+		merged[typ] = n
+	}
+	for typ, n := range limits {
+		merged[typ] = n
+	}
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	rateLimiters = map[string]*rateLimiter{}
+	for typ, n := range merged {
+		if n > 0 {
+			rateLimiters[typ] = newRateLimiter(n)
+		}
+	}
+}
+
+// throttle blocks until a message of the notifier type may be sent.
+func throttle(typ string) {
+	rateLimitersMu.Lock()
+	l := rateLimiters[typ]
+	rateLimitersMu.Unlock()
+	if l == nil {
+		return
+	}
+	if d := l.wait(); d > 0 {
+		slog.Info("rate limited, message queued", "notifier", typ, "waited", d.String())
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// notifyRenewed tells the group's channels that the certificates of renewed
+// have been replaced, and resolves their open incidents on the notifiers
+// that keep them.
+func (group *WatchGroup) notifyRenewed(rep *Report, renewed []Result) error {
+	if len(renewed) == 0 {
+		return nil
+	}
+	lines := make([]string, len(renewed))
+	for i := range renewed {
+		lines[i] = renewed[i].messages.renewedLine(&renewed[i])
+		slog.Info("certificate renewed", "group", group.Name, "site", renewed[i].Site, "expire", renewed[i].Expiry.Format("2006-01-02"))
+	}
+	text := strings.Join(lines, "\n")
+	return group.deliver(slog.LevelInfo, text, func(ch channel) error {
+		if res, ok := ch.Notifier.(Resolver); ok {
+			return res.Resolve(rep.Group, renewed)
+		}
+		return ch.Send(text, slog.LevelInfo)
+	})
+}
+
+// notifyIssuerChanged warns the group's channels about sites whose
+// certificate now comes from another CA, which is rarely a routine renewal.
+func (group *WatchGroup) notifyIssuerChanged(reissued []Result) error {
+	if len(reissued) == 0 {
+		return nil
+	}
+	lines := make([]string, len(reissued))
+	for i := range reissued {
+		lines[i] = reissued[i].messages.issuerChangedLine(&reissued[i])
+		slog.Warn("certificate issuer changed", "group", group.Name, "site", reissued[i].Site, "from", reissued[i].PrevIssuer, "to", reissued[i].IssuerDN)
+	}
+	return group.notifyText(strings.Join(lines, "\n"), slog.LevelWarn)
+}
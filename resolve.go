@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// checksAllIPs reports whether site is checked on every address its host
+// resolves to rather than on whichever one the dialer picks.
+func (group *WatchGroup) checksAllIPs(site *Site) bool {
+	return site.dialed() && (site.AllIPs || group.AllIPs)
+}
+
+// checkIPs checks site on each address its host name resolves to, so
+// backends behind round-robin DNS that serve different certificates are all
+// seen. Each result is named after the site with the IP in brackets, as in
+// "example.com [192.0.2.1]", and held to the site's host name.
+func (group *WatchGroup) checkIPs(ctx context.Context, site *Site, today time.Time) []Result {
+	scheme, _, ok := strings.Cut(site.Addr, "://")
+	if !ok {
+		scheme = "https"
+	}
+	_, addr, _ := siteTarget(site.Addr)
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return []Result{group.check(ctx, site, today)}
+	}
+	resolveCtx, cancel := context.WithTimeout(ctx, group.timeout(site))
+	defer cancel()
+	network := "ip"
+	if nets := group.networks(site); len(nets) == 1 {
+		network = strings.Replace(nets[0], "tcp", "ip", 1) // a forced version
+	}
+	ips, err := net.DefaultResolver.LookupIP(resolveCtx, network, host)
+	if err != nil {
+		slog.Error("failed to resolve site:", "site", site, "error", err)
+		return []Result{{Site: site.Addr, Status: StatusFailed, Err: &checkError{Phase: "resolve", Err: err}, Attempts: 1, messages: group.messages}}
+	}
+	results := make([]Result, 0, len(ips))
+	for _, ip := range ips {
+		s := *site
+		s.Addr = scheme + "://" + net.JoinHostPort(ip.String(), port)
+		s.ServerName = site.serverName()
+		r := group.check(ctx, &s, today)
+		r.Site, r.IP = site.Addr+" ["+ip.String()+"]", ip.String()
+		results = append(results, r)
+	}
+	return results
+}
+
+// addr returns the configured site r is a result for.
+func (r *Result) addr() string {
+	site := r.Site
+	if r.IP != "" {
+		site = strings.TrimSuffix(site, " ["+r.IP+"]")
+	}
+	if r.MX != "" {
+		site = strings.TrimSuffix(site, " ["+r.MX+"]")
+	}
+	return site
+}
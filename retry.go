@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how failed notifications are retried. The delay
+// doubles after every attempt up to MaxDelay, with ±50% jitter so channels
+// that failed together do not retry in lockstep. Notifications that still
+// fail are appended to DeadLetter as JSON lines.
+type RetryPolicy struct {
+	Attempts   int    `toml:"attempts"`  // total tries, default 3
+	Delay      int    `toml:"delay"`     // seconds before the first retry, default 2
+	MaxDelay   int    `toml:"max_delay"` // seconds, default 60
+	DeadLetter string `toml:"dead_letter"`
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.Attempts <= 0 {
+		p.Attempts = 3
+	}
+	if p.Delay <= 0 {
+		p.Delay = 2
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 60
+	}
+}
+
+// backoff is the wait after the given failed attempt, counting from 1.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := time.Duration(p.Delay) * time.Second << (attempt - 1)
+	if limit := time.Duration(p.MaxDelay) * time.Second; d > limit || d <= 0 {
+		d = limit
+	}
+	return time.Duration(float64(d) * (0.5 + rand.Float64()))
+}
+
+// do calls fn until it succeeds or the attempts are used up, and returns
+// the last error.
+func (p *RetryPolicy) do(group, name string, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= p.Attempts {
+			return err
+		}
+		wait := p.backoff(attempt)
+		slog.Warn("notification attempt failed, retrying", "group", group, "notifier", name, "attempt", attempt, "wait", wait.String(), "error", err)
+		time.Sleep(wait)
+	}
+}
+
+type deadLetter struct {
+	Time     time.Time `json:"time"`
+	Group    string    `json:"group"`
+	Notifier string    `json:"notifier"`
+	Level    string    `json:"level"`
+	Message  string    `json:"message"`
+	Error    string    `json:"error"`
+}
+
+var deadLetterMu sync.Mutex
+
+// bury records a notification that could not be delivered. Without a dead
+// letter file the message is only logged.
+func (p *RetryPolicy) bury(d deadLetter) {
+	if p.DeadLetter == "" {
+		slog.Error("undelivered notification", "group", d.Group, "notifier", d.Notifier, "message", d.Message)
+		return
+	}
+	line, err := json.Marshal(d)
+	if err != nil {
+		slog.Error("failed to encode dead letter", "error", err)
+		return
+	}
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+	f, err := os.OpenFile(p.DeadLetter, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		slog.Error("failed to open dead letter file", "path", p.DeadLetter, "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		slog.Error("failed to write dead letter", "path", p.DeadLetter, "error", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("serverchan", func(decode func(any) error) (Notifier, error) {
+		n := &ServerchanNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.SendKey == "" {
+			return nil, fmt.Errorf("serverchan: sendkey is required")
+		}
+		return n, nil
+	})
+}
+
+// ServerchanNotifier pushes to personal WeChat through ServerChan (方糖).
+// Both Turbo keys (SCT...) and Server³ keys (sctp<uid>t...) are accepted.
+type ServerchanNotifier struct {
+	SendKey string `toml:"sendkey"`
+}
+
+var serverchan3Key = regexp.MustCompile(`^sctp(\d+)t`)
+
+func (n *ServerchanNotifier) endpoint() string {
+	if m := serverchan3Key.FindStringSubmatch(n.SendKey); m != nil {
+		return fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], n.SendKey)
+	}
+	return "https://sctapi.ftqq.com/" + n.SendKey + ".send"
+}
+
+func (n *ServerchanNotifier) Send(msg string, level slog.Level) error {
+	title, body := splitMessage(msg)
+	// the title is capped at 32 characters, the rest goes to the markdown body
+	if r := []rune(title); len(r) > 32 {
+		title = string(r[:32])
+	}
+	form := url.Values{"title": {title}, "desp": {strings.ReplaceAll(body, "\n", "\n\n")}}
+	header := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	status, resp, err := doHTTP("POST", n.endpoint(), header, form.Encode())
+	if err != nil {
+		return fmt.Errorf("serverchan: %w", err)
+	}
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(resp, &result)
+	if status != 200 || result.Code != 0 {
+		return fmt.Errorf("serverchan: status %d, code %d: %s", status, result.Code, result.Message)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+func serviceCommand(args []string, conf string) error {
+	return errors.New("service mode is only available on Windows")
+}
+
+func runAsService(config *Config, load func() (*Config, error)) bool { return false }
@@ -0,0 +1,210 @@
+//go:build windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "crtwtch"
+
+// serviceCommand manages the Windows service: install registers it to run
+// this executable in daemon mode with the config at conf, uninstall removes
+// it, start and stop control it.
+func serviceCommand(args []string, conf string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: crtwtch [-c config] service install|uninstall|start|stop")
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	switch args[0] {
+	case "install":
+		return installService(m, conf)
+	case "uninstall":
+		return uninstallService(m)
+	case "start":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		return s.Start()
+	case "stop":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		return stopService(s)
+	}
+	return fmt.Errorf("unknown service command %q", args[0])
+}
+
+func installService(m *mgr.Mgr, conf string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// the service starts in System32, so the config path must be absolute
+	conf, err = filepath.Abs(conf)
+	if err != nil {
+		return err
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "crtwtch",
+		Description: "Watches TLS certificates and alerts before they expire.",
+		StartType:   mgr.StartAutomatic,
+	}, "-d", "-c", conf)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		_ = s.Delete()
+		return fmt.Errorf("event log source: %w", err)
+	}
+	return nil
+}
+
+func uninstallService(m *mgr.Mgr) error {
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(serviceName)
+}
+
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service did not stop in time")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAsService runs the daemon under the service control manager, logging
+// to the event log. It reports false when the process was not started as a
+// service.
+func runAsService(config *Config, load func() (*Config, error)) bool {
+	if ok, err := svc.IsWindowsService(); err != nil || !ok {
+		return false
+	}
+	elog, err := eventlog.Open(serviceName)
+	if err == nil {
+		defer elog.Close()
+		slog.SetDefault(slog.New(newEventLogHandler(elog)))
+	}
+	if err := svc.Run(serviceName, &service{config: config, load: load}); err != nil {
+		slog.Error("service failed", "error", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+type service struct {
+	config *Config
+	load   func() (*Config, error)
+}
+
+func (s *service) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		daemon(ctx, s.config, s.load)
+		close(done)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				// like SIGTERM, let a pass in flight finish
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// eventLogHandler formats records like the text handler and writes each one
+// as an event of matching severity.
+type eventLogHandler struct {
+	slog.Handler
+	w *eventWriter
+}
+
+type eventWriter struct {
+	mu   sync.Mutex
+	elog *eventlog.Log
+	buf  bytes.Buffer
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func newEventLogHandler(elog *eventlog.Log) *eventLogHandler {
+	w := &eventWriter{elog: elog}
+	return &eventLogHandler{Handler: slog.NewTextHandler(w, nil), w: w}
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	h.w.buf.Reset()
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(h.w.buf.String(), "\n")
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.w.elog.Error(1, msg)
+	case r.Level >= slog.LevelWarn:
+		return h.w.elog.Warning(1, msg)
+	}
+	return h.w.elog.Info(1, msg)
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{Handler: h.Handler.WithAttrs(attrs), w: h.w}
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{Handler: h.Handler.WithGroup(name), w: h.w}
+}
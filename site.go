@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Site is one entry of a group's sites list. It is either a bare
+// "host[:port]" string, with a scheme or as "mx:domain" for the domain's
+// mail hosts, or an inline table with per-site options:
+//
+//	sites = ["example.com", { addr = "slow.example.com", timeout = 30 }]
+//
+// A host with several TLS ports lists them as "example.com:443,8443" or in
+// ports, and is checked as one site per port.
+type Site struct {
+	Addr    string `toml:"addr"`
+	Ports   []int  `toml:"ports"`
+	Timeout int    `toml:"timeout"` // seconds, overrides the group and global timeout
+	// SlowHandshake overrides the group and global slow_handshake.
+	SlowHandshake int `toml:"slow_handshake"`
+	// Password opens PKCS#12 files; JKS certificates are readable without
+	// it, but with it the keystore's integrity is checked too.
+	Password string `toml:"password"`
+	// ServerName is the host name to ask for and to hold the certificate
+	// to, for sites dialed at an address such as a load balancer's IP.
+	ServerName string `toml:"server_name"`
+	SNI        string `toml:"sni"` // another name for server_name
+	// Pins are the public keys or certificates the site is expected to
+	// present, see parsePin; a chain matching none of them is alerted.
+	Pins []string `toml:"pins"`
+	// Expect declares the leaf certificate, see Expect.
+	Expect *Expect `toml:"expect"`
+	// SharedKey exempts the site from duplicate_keys, for keys that are
+	// meant to be served by several hosts.
+	SharedKey bool `toml:"shared_key"`
+	// AllIPs checks the site on every address its host resolves to, with
+	// a result per IP.
+	AllIPs bool   `toml:"all_ips"`
+	Family string `toml:"family"` // overrides the group and global family
+	// Redline, CARedline, Critical and RemindAt override the group's, for
+	// a site whose certificates are renewed on another schedule than the
+	// rest.
+	Redline   int   `toml:"redline"`
+	CARedline int   `toml:"ca_redline"`
+	Critical  int   `toml:"critical"`
+	RemindAt  []int `toml:"remind_at"`
+	ClientCert
+	CheckRetry
+	Hooks
+	Maintenance []Window `toml:"maintenance"`
+}
+
+// CheckRetry re-checks a failing site before reporting it, so a single
+// reset connection does not page anyone. It can be set globally, per group
+// and per site; the most specific non-zero value of each field wins.
+type CheckRetry struct {
+	Retries      int     `toml:"retries"`       // extra attempts, default 0
+	RetryDelay   int     `toml:"retry_delay"`   // seconds before the first retry, default 1
+	RetryBackoff float64 `toml:"retry_backoff"` // delay multiplier per attempt, default 2
+}
+
+func (s *Site) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		*s = Site{Addr: v}
+	case map[string]any:
+		// round-trip through the encoder so the table gets the usual
+		// decoding rules, including rejecting unknown keys
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return err
+		}
+		type plain Site
+		var p plain
+		md, err := toml.Decode(buf.String(), &p)
+		if err != nil {
+			return err
+		}
+		if keys := md.Undecoded(); len(keys) > 0 {
+			return fmt.Errorf("site %v: unknown keys %v", v["addr"], keys)
+		}
+		*s = Site(p)
+	default:
+		return fmt.Errorf("site must be a string or a table, got %T", v)
+	}
+	if strings.TrimSpace(s.Addr) == "" {
+		return fmt.Errorf("site addr is empty")
+	}
+	if s.SNI != "" {
+		if s.ServerName != "" && s.ServerName != s.SNI {
+			return fmt.Errorf("site %s: sni and server_name differ", s.Addr)
+		}
+		s.ServerName = s.SNI
+	}
+	if err := s.splitPorts(); err != nil {
+		return fmt.Errorf("site %s: %w", s.Addr, err)
+	}
+	if domain, ok := mxDomain(s.Addr); ok {
+		if err := validMXDomain(domain); err != nil {
+			return fmt.Errorf("site %s: %w", s.Addr, err)
+		}
+	} else if _, _, err := siteTarget(s.Addr); err != nil {
+		return fmt.Errorf("site %s: %w", s.Addr, err)
+	}
+	if err := validFamily(s.Family); err != nil {
+		return fmt.Errorf("site %s: %w", s.Addr, err)
+	}
+	if err := s.ClientCert.load(); err != nil {
+		return fmt.Errorf("site %s: %w", s.Addr, err)
+	}
+	for _, p := range s.Pins {
+		if _, err := parsePin(p); err != nil {
+			return fmt.Errorf("site %s: %w", s.Addr, err)
+		}
+	}
+	if s.Expect != nil {
+		if err := s.Expect.load(); err != nil {
+			return fmt.Errorf("site %s: %w", s.Addr, err)
+		}
+	}
+	for _, mark := range s.RemindAt {
+		if mark < 1 {
+			return fmt.Errorf("site %s: remind_at days must be at least 1, got %d", s.Addr, mark)
+		}
+	}
+	slices.Sort(s.RemindAt)
+	return nil
+}
+
+func (s *Site) String() string { return s.Addr }
+
+// splitPorts moves the ports of an address written as "host:443,8443" to
+// Ports, and checks that ports are only given to servers without a port in
+// their address.
+func (s *Site) splitPorts() error {
+	if i := strings.LastIndex(s.Addr, ":"); i >= 0 && strings.Contains(s.Addr[i:], ",") {
+		if len(s.Ports) > 0 {
+			return fmt.Errorf("ports given both in addr and in ports")
+		}
+		for _, p := range strings.Split(s.Addr[i+1:], ",") {
+			port, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return fmt.Errorf("bad port %q", p)
+			}
+			s.Ports = append(s.Ports, port)
+		}
+		s.Addr = s.Addr[:i]
+	}
+	if len(s.Ports) == 0 {
+		return nil
+	}
+	if _, ok := mxDomain(s.Addr); ok || !s.dialed() {
+		return fmt.Errorf("ports only apply to servers")
+	}
+	_, rest, _ := cutScheme(s.Addr)
+	if _, _, err := net.SplitHostPort(rest); err == nil {
+		return fmt.Errorf("ports given to an address with a port")
+	}
+	for _, p := range s.Ports {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("bad port %d", p)
+		}
+	}
+	return nil
+}
+
+// expandPorts returns sites with each site that has ports replaced by one
+// site per port, which gets the options of the original.
+func expandPorts(sites []Site) []Site {
+	out := make([]Site, 0, len(sites))
+	for _, s := range sites {
+		if len(s.Ports) == 0 {
+			out = append(out, s)
+			continue
+		}
+		scheme, host, _ := cutScheme(s.Addr)
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		for _, p := range s.Ports {
+			e := s
+			e.Addr, e.Ports = scheme+net.JoinHostPort(host, strconv.Itoa(p)), nil
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// cutScheme splits addr into its scheme with the "://", empty when it has
+// none, and the rest.
+func cutScheme(addr string) (string, string, bool) {
+	scheme, rest, ok := strings.Cut(addr, "://")
+	if !ok {
+		return "", addr, false
+	}
+	return scheme + "://", rest, true
+}
+
+// dialed reports whether the site's certificate comes from a server rather
+// than a file. mx: sites are not dialed themselves, their mail hosts are.
+func (s *Site) dialed() bool {
+	if _, ok := mxDomain(s.Addr); ok {
+		return false
+	}
+	proto, _, err := siteTarget(s.Addr)
+	return err == nil && proto.read == nil
+}
+
+// serverName is the name the site's certificate has to be valid for: its
+// server_name, or the host it is dialed at. It is empty for sites dialed at
+// an IP address without a server_name and for sites read from files.
+func (s *Site) serverName() string {
+	if s.ServerName != "" {
+		return s.ServerName
+	}
+	if !s.dialed() {
+		return ""
+	}
+	_, addr, _ := siteTarget(s.Addr)
+	host, _, _ := net.SplitHostPort(addr)
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	return host
+}
+
+const defaultTimeout = 10 * time.Second
+
+// timeout picks the most specific configured check timeout for the site.
+func (group *WatchGroup) timeout(site *Site) time.Duration {
+	for _, t := range []int{site.Timeout, group.Timeout, group.config.Timeout} {
+		if t > 0 {
+			return time.Duration(t) * time.Second
+		}
+	}
+	return defaultTimeout
+}
+
+// slowHandshake picks the most specific handshake time limit for the site,
+// 0 for none.
+func (group *WatchGroup) slowHandshake(site *Site) time.Duration {
+	for _, ms := range []int{site.SlowHandshake, group.SlowHandshake, group.config.SlowHandshake} {
+		if ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// ClientCert is the certificate presented to servers that ask for one, for
+// those that refuse the handshake without it. It can be set per group and
+// per site, the site's winning.
+type ClientCert struct {
+	Cert string `toml:"client_cert"` // PEM certificate chain
+	Key  string `toml:"client_key"`  // PEM private key, default in client_cert
+	cert *tls.Certificate
+}
+
+func (c *ClientCert) load() error {
+	if c.Cert == "" {
+		if c.Key != "" {
+			return fmt.Errorf("client_key without client_cert")
+		}
+		return nil
+	}
+	key := c.Key
+	if key == "" {
+		key = c.Cert
+	}
+	cert, err := tls.LoadX509KeyPair(c.Cert, key)
+	if err != nil {
+		return fmt.Errorf("client_cert: %w", err)
+	}
+	c.cert = &cert
+	return nil
+}
+
+// clientCert returns the certificate the site authenticates with, nil for
+// none.
+func (group *WatchGroup) clientCert(site *Site) *tls.Certificate {
+	if site.ClientCert.cert != nil {
+		return site.ClientCert.cert
+	}
+	return group.ClientCert.cert
+}
+
+// families are the IP versions a site can be dialed over, each with the
+// networks tried in turn: one to force the version, both to prefer one and
+// fall back to the other.
+var families = map[string][]string{
+	"":            {"tcp"},
+	"ipv4":        {"tcp4"},
+	"ipv6":        {"tcp6"},
+	"prefer_ipv4": {"tcp4", "tcp6"},
+	"prefer_ipv6": {"tcp6", "tcp4"},
+}
+
+func validFamily(family string) error {
+	if _, ok := families[family]; !ok {
+		return fmt.Errorf("unknown family %q, want ipv4, ipv6, prefer_ipv4 or prefer_ipv6", family)
+	}
+	return nil
+}
+
+// networks returns the networks to dial the site over, by the most specific
+// family setting.
+func (group *WatchGroup) networks(site *Site) []string {
+	for _, f := range []string{site.Family, group.Family, group.config.Family} {
+		if f != "" {
+			return families[f]
+		}
+	}
+	return families[""]
+}
+
+// checkRetry resolves the retry settings of the site.
+func (group *WatchGroup) checkRetry(site *Site) CheckRetry {
+	r := CheckRetry{RetryDelay: 1, RetryBackoff: 2}
+	for _, c := range []CheckRetry{group.config.CheckRetry, group.CheckRetry, site.CheckRetry} {
+		if c.Retries > 0 {
+			r.Retries = c.Retries
+		}
+		if c.RetryDelay > 0 {
+			r.RetryDelay = c.RetryDelay
+		}
+		if c.RetryBackoff > 0 {
+			r.RetryBackoff = c.RetryBackoff
+		}
+	}
+	return r
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+func init() {
+	RegisterNotifier("slack", func(decode func(any) error) (Notifier, error) {
+		n := &SlackNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.WebhookURL == "" {
+			return nil, fmt.Errorf("slack: webhook_url is required")
+		}
+		return n, nil
+	})
+}
+
+// SlackNotifier posts to a Slack incoming webhook, one colored attachment
+// per alert.
+type SlackNotifier struct {
+	WebhookURL string `toml:"webhook_url"`
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func slackColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "#a30200"
+	case level >= slog.LevelWarn:
+		return "#daa038"
+	}
+	return "#2eb886"
+}
+
+func slackSection(color, text string) slackAttachment {
+	return slackAttachment{
+		Color:  color,
+		Blocks: []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}},
+	}
+}
+
+func (n *SlackNotifier) Send(msg string, level slog.Level) error {
+	return n.post(slackMessage{
+		Text:        msg,
+		Attachments: []slackAttachment{slackSection(slackColor(level), msg)},
+	})
+}
+
+func (n *SlackNotifier) SendReport(rep *Report) error {
+	shown := rep.Shown()
+	if len(shown) == 0 {
+		return n.Send(rep.Title(), slog.LevelInfo)
+	}
+	msg := slackMessage{Text: rep.Title()}
+	for _, r := range shown {
+		msg.Attachments = append(msg.Attachments, slackSection(slackColor(r.Status.Level()), r.Message()))
+	}
+	return n.post(msg)
+}
+
+func (n *SlackNotifier) post(msg slackMessage) error {
+	if _, err := postJSON(n.WebhookURL, msg); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	return nil
+}
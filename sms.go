@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("sms", func(decode func(any) error) (Notifier, error) {
+		n := &SMSNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if len(n.To) == 0 {
+			return nil, fmt.Errorf("sms: to is required")
+		}
+		factory, ok := smsDrivers[n.Driver]
+		if !ok {
+			return nil, fmt.Errorf("sms: unknown driver %q", n.Driver)
+		}
+		driver, err := factory(decode)
+		if err != nil {
+			return nil, fmt.Errorf("sms: %s: %w", n.Driver, err)
+		}
+		n.driver = driver
+		return n, nil
+	})
+}
+
+// SMSDriver delivers one short text to a list of phone numbers.
+type SMSDriver interface {
+	SendSMS(to []string, text string) error
+}
+
+// smsSitesSender is implemented by drivers that are better served by the
+// bare list of expired sites than a full sentence, such as template based
+// gateways with short variables.
+type smsSitesSender interface {
+	SendSites(to []string, sites []string) error
+}
+
+// smsDrivers builds a driver from the same table as the notifier, so the
+// driver specific keys sit next to driver and to.
+var smsDrivers = map[string]func(decode func(any) error) (SMSDriver, error){
+	"twilio": newTwilioDriver,
+	"aliyun": newAliyunDriver,
+}
+
+// SMSNotifier texts on-call phones. SMS is expensive and intrusive, so only
+// the highest severity gets through: expired certificates in reports, and
+// error level plain messages.
+type SMSNotifier struct {
+	Driver string   `toml:"driver"`
+	To     []string `toml:"to"`
+
+	driver SMSDriver
+}
+
+func (n *SMSNotifier) Send(msg string, level slog.Level) error {
+	if level < slog.LevelError {
+		return nil
+	}
+	return n.driver.SendSMS(n.To, msg)
+}
+
+func (n *SMSNotifier) SendReport(rep *Report) error {
+	var sites []string
+	for _, r := range rep.Results {
+		if r.Status == StatusExpired {
+			sites = append(sites, r.Site)
+		}
+	}
+	if len(sites) == 0 {
+		slog.Debug("sms: nothing expired, skipping", "group", rep.Group)
+		return nil
+	}
+	if d, ok := n.driver.(smsSitesSender); ok {
+		return d.SendSites(n.To, sites)
+	}
+	return n.driver.SendSMS(n.To, fmt.Sprintf(rep.labels().SMSExpired, rep.Group, len(sites), strings.Join(sites, ", ")))
+}
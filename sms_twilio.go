@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+type twilioDriver struct {
+	AccountSID string `toml:"account_sid"`
+	AuthToken  string `toml:"auth_token"`
+	From       string `toml:"from"`
+}
+
+func newTwilioDriver(decode func(any) error) (SMSDriver, error) {
+	d := &twilioDriver{}
+	if err := decode(d); err != nil {
+		return nil, err
+	}
+	if d.AccountSID == "" || d.AuthToken == "" || d.From == "" {
+		return nil, fmt.Errorf("account_sid, auth_token and from are required")
+	}
+	return d, nil
+}
+
+// SendSMS sends one message per recipient through the Twilio Messages API.
+func (d *twilioDriver) SendSMS(to []string, text string) error {
+	u := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(d.AccountSID) + "/Messages.json"
+	header := map[string]string{
+		"Content-Type":  "application/x-www-form-urlencoded",
+		"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(d.AccountSID+":"+d.AuthToken)),
+	}
+	var errs []error
+	for _, phone := range to {
+		form := url.Values{"To": {phone}, "From": {d.From}, "Body": {text}}
+		status, resp, err := doHTTP("POST", u, header, form.Encode())
+		if err == nil && status != 201 {
+			err = fmt.Errorf("unexpected status code %d: %s", status, resp)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("twilio %s: %w", phone, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHJump tunnels the checks of a group through an SSH bastion, so servers
+// on networks only it can reach are checked from one central crtwtch. The
+// bastion resolves the sites' names. It has to be in known_hosts.
+type SSHJump struct {
+	Host       string `toml:"host"` // host[:port], port 22 by default
+	User       string `toml:"user"`
+	Key        string `toml:"key"` // private key file
+	Passphrase string `toml:"passphrase"`
+	Agent      bool   `toml:"agent"`       // also offer the keys of the agent at SSH_AUTH_SOCK
+	KnownHosts string `toml:"known_hosts"` // default ~/.ssh/known_hosts
+
+	config *ssh.ClientConfig
+	mu     sync.Mutex
+	client *ssh.Client // connected on first use, kept across checks
+}
+
+// load reads the key and known hosts of the jump host.
+func (j *SSHJump) load() error {
+	if j.Host == "" || j.User == "" {
+		return errors.New("ssh_jump needs host and user")
+	}
+	if j.Key == "" && !j.Agent {
+		return errors.New("ssh_jump needs a key or agent = true")
+	}
+	var auth []ssh.AuthMethod
+	if j.Key != "" {
+		pem, err := os.ReadFile(expandHome(j.Key))
+		if err != nil {
+			return fmt.Errorf("ssh_jump key: %w", err)
+		}
+		var signer ssh.Signer
+		if j.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(j.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return fmt.Errorf("ssh_jump key %s: %w", j.Key, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if j.Agent && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return errors.New("ssh_jump agent: SSH_AUTH_SOCK is not set")
+	}
+	known := j.KnownHosts
+	if known == "" {
+		known = "~/.ssh/known_hosts"
+	}
+	hostKeys, err := knownhosts.New(expandHome(known))
+	if err != nil {
+		return fmt.Errorf("ssh_jump known_hosts: %w", err)
+	}
+	j.config = &ssh.ClientConfig{User: j.User, Auth: auth, HostKeyCallback: hostKeys}
+	return nil
+}
+
+// dial connects to addr from the jump host, connecting to the jump host
+// first if there is no connection yet or the last one broke.
+func (j *SSHJump) dial(ctx context.Context, addr string, timeout time.Duration) (net.Conn, error) {
+	client, err := j.connect(ctx, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ssh_jump %s: %w", j.Host, err)
+	}
+	type dialed struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan dialed, 1)
+	go func() {
+		conn, err := client.Dial("tcp", addr)
+		done <- dialed{conn, err}
+	}()
+	select {
+	case d := <-done:
+		if d.err != nil {
+			var openErr *ssh.OpenChannelError
+			if !errors.As(d.err, &openErr) {
+				// not refused by the jump host, so the connection is gone
+				j.drop(client)
+			}
+			return nil, d.err
+		}
+		return &sshConn{Conn: d.conn}, nil
+	case <-time.After(timeout):
+	case <-ctx.Done():
+	}
+	go func() {
+		if d := <-done; d.conn != nil {
+			d.conn.Close()
+		}
+	}()
+	return nil, fmt.Errorf("ssh_jump %s: dial %s: %w", j.Host, addr, context.DeadlineExceeded)
+}
+
+func (j *SSHJump) connect(ctx context.Context, timeout time.Duration) (*ssh.Client, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.client != nil {
+		return j.client, nil
+	}
+	addr := withPort(j.Host, "22")
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	config := j.config
+	if j.Agent {
+		// the agent signs during the handshake, so it is only needed until then
+		agentConn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("agent: %w", err)
+		}
+		defer agentConn.Close()
+		withAgent := *config
+		withAgent.Auth = append(slices.Clip(config.Auth), ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		config = &withAgent
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	j.client = ssh.NewClient(c, chans, reqs)
+	slog.Info("connected to the ssh jump host", "host", j.Host)
+	return j.client, nil
+}
+
+// close closes the connection to the jump host, if one is open.
+func (j *SSHJump) close() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.client != nil {
+		j.client.Close()
+		j.client = nil
+	}
+}
+
+// closeSSHJumps closes the connections to the jump hosts of config, once a
+// reload replaced it and no pass uses them anymore.
+func (config *Config) closeSSHJumps() {
+	config.SSHJump.close()
+	for i := range config.Groups {
+		config.Groups[i].SSHJump.close()
+	}
+}
+
+// drop forgets client, if it is still the current one, so the next dial
+// reconnects.
+func (j *SSHJump) drop(client *ssh.Client) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.client == client {
+		client.Close()
+		j.client = nil
+	}
+}
+
+// sshConn adds the deadlines SSH channels lack, enforced by closing the
+// channel when one passes.
+type sshConn struct {
+	net.Conn
+	mu      sync.Mutex
+	timer   *time.Timer
+	expired bool
+}
+
+func (c *sshConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if !t.IsZero() {
+		c.timer = time.AfterFunc(time.Until(t), func() {
+			c.mu.Lock()
+			c.expired = true
+			c.mu.Unlock()
+			c.Conn.Close()
+		})
+	}
+	return nil
+}
+
+func (c *sshConn) SetReadDeadline(t time.Time) error  { return c.SetDeadline(t) }
+func (c *sshConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+
+func (c *sshConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	return n, c.timedOut(err)
+}
+
+func (c *sshConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	return n, c.timedOut(err)
+}
+
+// timedOut reports err as a timeout when it comes from a passed deadline.
+func (c *sshConn) timedOut(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.expired {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
+
+// sshJump returns the jump host the group's checks go through, nil for none.
+func (group *WatchGroup) sshJump() *SSHJump {
+	if group.SSHJump != nil {
+		return group.SSHJump
+	}
+	return group.config.SSHJump
+}
+
+// expandHome replaces a leading "~/" of path with the home directory.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
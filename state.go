@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateStore remembers per site the status it was last reported with, so a
+// condition is not alerted again on every run. It is saved to path after
+// each change, or only kept in memory when path is empty.
+type stateStore struct {
+	mu       sync.Mutex
+	path     string
+	Sites    map[string]*siteState `json:"sites"`              // keyed by stateKey
+	Snoozes  map[string]snooze     `json:"snoozes,omitempty"`  // keyed by site addr
+	Digests  map[string]time.Time  `json:"digests,omitempty"`  // last digest per group
+	Weeklies map[string]time.Time  `json:"weeklies,omitempty"` // last weekly report per group
+}
+
+type siteState struct {
+	Status  string    `json:"status,omitempty"` // status last sent
+	Alerted time.Time `json:"alerted,omitzero"` // when a problem was last sent
+	Mark    int       `json:"mark,omitempty"`   // reminder mark last sent
+
+	// history for renewal notices and the weekly report
+	Expiry     time.Time `json:"expiry,omitzero"` // NotAfter last seen
+	Serial     string    `json:"serial,omitempty"`
+	PrevExpiry time.Time `json:"prev_expiry,omitzero"`
+	Renewed    time.Time `json:"renewed,omitzero"` // when Expiry last moved forward
+	Issuer     string    `json:"issuer,omitempty"` // DN of the issuer last seen
+	IssuerOrg  string    `json:"issuer_org,omitempty"`
+	Checks     int       `json:"checks,omitempty"` // since the last weekly report
+	Failures   int       `json:"failures,omitempty"`
+	// Fingerprint is the SHA-256 of the leaf last seen, which also changes
+	// when a certificate is reissued with the same expiry and serial.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// what checkDuplicates compares
+	Host      string    `json:"host,omitempty"`
+	KeyPin    string    `json:"key_pin,omitempty"` // of the leaf
+	SharedKey bool      `json:"shared_key,omitempty"`
+	Seen      time.Time `json:"seen,omitzero"`
+}
+
+func stateKey(group, site string) string { return group + "/" + site }
+
+// replaced reports whether the leaf with fingerprint is another certificate
+// than the one last seen. Results without a leaf never are.
+func (st *siteState) replaced(fingerprint string) bool {
+	return st.Fingerprint != "" && fingerprint != "" && fingerprint != st.Fingerprint
+}
+
+// loadState reads the state file at path. A missing file is an empty state.
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path, Sites: map[string]*siteState{}, Snoozes: map[string]snooze{}, Digests: map[string]time.Time{}, Weeklies: map[string]time.Time{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Sites == nil {
+		s.Sites = map[string]*siteState{}
+	}
+	if s.Snoozes == nil {
+		s.Snoozes = map[string]snooze{}
+	}
+	if s.Digests == nil {
+		s.Digests = map[string]time.Time{}
+	}
+	if s.Weeklies == nil {
+		s.Weeklies = map[string]time.Time{}
+	}
+	return s, nil
+}
+
+// save writes the state through a temporary file, so a crash never leaves
+// a truncated state behind. The caller holds s.mu.
+func (s *stateStore) save() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		slog.Error("failed to encode state", "error", err)
+		return
+	}
+	if err := writeFileAtomic(s.path, append(data, '\n'), 0o600); err != nil {
+		slog.Error("failed to save state", "path", s.path, "error", err)
+	}
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory, so readers never see it half written.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (group *WatchGroup) cooldown() time.Duration {
+	seconds := group.config.Cooldown
+	if group.Cooldown > 0 {
+		seconds = group.Cooldown
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// notifyMode is "always" (default) to send every run's result, or "change"
+// to send only the sites whose status changed since they were last sent.
+func (group *WatchGroup) notifyMode() string {
+	if group.NotifyMode != "" {
+		return group.NotifyMode
+	}
+	if group.config.NotifyMode != "" {
+		return group.config.NotifyMode
+	}
+	return "always"
+}
+
+// tracksState reports whether the group filters alerts on the state store.
+func (group *WatchGroup) tracksState() bool {
+	return group.cooldown() > 0 || group.notifyMode() == "change" || len(group.RemindAt) > 0
+}
+
+// filterReport returns the part of rep to send, and whether it needs sending
+// at all. With reminder marks, a warning is only sent when it reaches a mark
+// lower than the one last sent. In change mode only the sites whose status
+// changed are kept, and nothing is sent when none did. Alerts whose
+// condition was already sent within the cooldown are dropped; if that drops
+// every alert of a report that had some, nothing is sent either.
+func (group *WatchGroup) filterReport(rep *Report) (*Report, bool) {
+	if !group.tracksState() {
+		return rep, true
+	}
+	cooldown := group.cooldown()
+	onChange := group.notifyMode() == "change"
+	state := group.config.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	out := *rep
+	out.Results = make([]Result, 0, len(rep.Results))
+	for _, r := range rep.Results {
+		status := r.Status.String()
+		prev := siteState{Status: StatusOK.String()} // new sites start out OK
+		if st := state.Sites[stateKey(group.Name, r.Site)]; st != nil && st.Status != "" {
+			prev = *st
+		}
+		// CA certificates warned before the highest mark have no mark and
+		// are filtered like any other alert
+		if mark := group.reminderMark(group.site(r.addr()), r.DaysLeft); r.Status.expiring() && mark > 0 {
+			if status == prev.Status && prev.Mark > 0 && mark >= prev.Mark {
+				continue
+			}
+			out.Results = append(out.Results, r)
+			continue
+		}
+		if onChange && status == prev.Status {
+			continue
+		}
+		if r.Status != StatusOK && status == prev.Status && rep.Date.Sub(prev.Alerted) < cooldown {
+			slog.Info("alert suppressed by cooldown", "group", group.Name, "site", r.Site, "status", status, "alerted", prev.Alerted.Format(time.DateTime))
+			continue
+		}
+		out.Results = append(out.Results, r)
+	}
+	if onChange {
+		out.listAll = true
+		return &out, len(out.Results) > 0
+	}
+	return &out, len(out.Alerts()) > 0 || len(rep.Alerts()) == 0
+}
+
+// markRecovered marks the OK results of rep whose site was last sent with a
+// problem, or never sent as far as the state knows, for notifiers that
+// close alerts of their own.
+func (group *WatchGroup) markRecovered(rep *Report) {
+	state := group.config.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for i := range rep.Results {
+		r := &rep.Results[i]
+		st := state.Sites[stateKey(group.Name, r.Site)]
+		r.recovered = r.Status == StatusOK && (st == nil || st.Status != StatusOK.String())
+	}
+}
+
+// recordState remembers the results of rep as sent. This happens even when
+// the group does not filter on it, so renewals of alerted certificates can
+// be told apart.
+func (group *WatchGroup) recordState(rep *Report) {
+	if len(rep.Results) == 0 {
+		return
+	}
+	state := group.config.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, r := range rep.Results {
+		key := stateKey(group.Name, r.Site)
+		st := state.Sites[key]
+		if st == nil {
+			st = &siteState{}
+			state.Sites[key] = st
+		}
+		st.Status = r.Status.String()
+		if r.Status != StatusOK {
+			st.Alerted = rep.Date
+		}
+		st.Mark = 0
+		if r.Status.expiring() {
+			st.Mark = group.reminderMark(group.site(r.addr()), r.DaysLeft)
+		}
+	}
+	state.save()
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestMarkRecovered(t *testing.T) {
+	state, _ := loadState("")
+	state.Sites[stateKey("g", "fixed.example.com")] = &siteState{Status: StatusExpired.String()}
+	state.Sites[stateKey("g", "fine.example.com")] = &siteState{Status: StatusOK.String()}
+	state.Sites[stateKey("g", "seen.example.com")] = &siteState{}
+	group := &WatchGroup{Name: "g", config: &Config{state: state}}
+	rep := &Report{Group: "g", Results: []Result{
+		{Site: "fixed.example.com", Status: StatusOK},
+		{Site: "fine.example.com", Status: StatusOK},
+		{Site: "seen.example.com", Status: StatusOK},
+		{Site: "new.example.com", Status: StatusOK},
+		{Site: "broken.example.com", Status: StatusExpired},
+	}}
+	group.markRecovered(rep)
+	want := map[string]bool{
+		"fixed.example.com":  true,
+		"fine.example.com":   false,
+		"seen.example.com":   true, // never sent
+		"new.example.com":    true,
+		"broken.example.com": false,
+	}
+	for _, r := range rep.Results {
+		if r.recovered != want[r.Site] {
+			t.Errorf("%s: recovered = %v, want %v", r.Site, r.recovered, want[r.Site])
+		}
+	}
+	group.recordState(rep)
+	group.markRecovered(rep)
+	for _, r := range rep.Results {
+		if r.recovered {
+			t.Errorf("%s: recovered again after the OK was sent", r.Site)
+		}
+	}
+}
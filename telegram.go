@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("telegram", func(decode func(any) error) (Notifier, error) {
+		n := &TelegramNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.BotToken == "" || n.ChatID == "" {
+			return nil, fmt.Errorf("telegram: bot_token and chat_id are required")
+		}
+		return n, nil
+	})
+}
+
+// TelegramNotifier sends messages through the Telegram Bot API.
+type TelegramNotifier struct {
+	BotToken string `toml:"bot_token"`
+	ChatID   string `toml:"chat_id"`
+}
+
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// telegramEscaper escapes every character MarkdownV2 reserves, which
+// includes the dots and dashes found in most hostnames.
+var telegramEscaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+func (n *TelegramNotifier) Send(msg string, level slog.Level) error {
+	return n.post(telegramEscaper.Replace(msg))
+}
+
+func (n *TelegramNotifier) SendReport(rep *Report) error {
+	lines := []string{"*" + telegramEscaper.Replace(rep.Title()) + "*"}
+	for _, r := range rep.Shown() {
+		lines = append(lines, telegramEscaper.Replace(r.Message()))
+	}
+	return n.post(strings.Join(lines, "\n"))
+}
+
+func (n *TelegramNotifier) post(text string) error {
+	body, err := postJSON("https://api.telegram.org/bot"+n.BotToken+"/sendMessage", telegramMessage{ChatID: n.ChatID, Text: text, ParseMode: "MarkdownV2"})
+	var resp telegramResponse
+	_ = json.Unmarshal(body, &resp)
+	if err != nil {
+		if resp.Description != "" {
+			return fmt.Errorf("telegram: %w: %s", err, resp.Description)
+		}
+		return fmt.Errorf("telegram: %w", err)
+	}
+	if !resp.OK {
+		return fmt.Errorf("telegram: %s", resp.Description)
+	}
+	return nil
+}
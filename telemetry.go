@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTel exports traces and metrics over OTLP/HTTP: a span per pass of a
+// group, with a child span per site checked, a span per notification
+// sent, and the gauges of the Prometheus exporter. The usual
+// OTEL_EXPORTER_OTLP_* and OTEL_RESOURCE_ATTRIBUTES variables apply, and
+// OTEL_EXPORTER_OTLP_ENDPOINT alone turns the export on.
+type OTel struct {
+	Endpoint string            `toml:"endpoint"` // base URL such as "http://collector:4318"
+	Headers  map[string]string `toml:"headers"`
+	Interval int               `toml:"interval"` // seconds between metric exports, default 60
+}
+
+const instrumentation = "github.com/chengongpp/crtwtch"
+
+// tracer is a no-op until setupTelemetry installs a provider.
+var tracer = otel.Tracer(instrumentation)
+
+// setupTelemetry starts exporting when o is set or the environment names an
+// OTLP endpoint. The returned function flushes and stops the export; it is
+// set up once per process, so config changes take a restart.
+func setupTelemetry(ctx context.Context, o *OTel) (shutdown func(), err error) {
+	if o == nil {
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+			return func() {}, nil
+		}
+		o = &OTel{}
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", "crtwtch")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	traceOpts := []otlptracehttp.Option{otlptracehttp.WithHeaders(o.Headers)}
+	metricOpts := []otlpmetrichttp.Option{otlpmetrichttp.WithHeaders(o.Headers)}
+	if o.Endpoint != "" {
+		base := strings.TrimSuffix(o.Endpoint, "/")
+		traceOpts = append(traceOpts, otlptracehttp.WithEndpointURL(base+"/v1/traces"))
+		metricOpts = append(metricOpts, otlpmetrichttp.WithEndpointURL(base+"/v1/metrics"))
+	}
+	traceExp, err := otlptracehttp.New(ctx, traceOpts...)
+	if err != nil {
+		return nil, err
+	}
+	metricExp, err := otlpmetrichttp.New(ctx, metricOpts...)
+	if err != nil {
+		return nil, err
+	}
+	interval := 60 * time.Second
+	if o.Interval > 0 {
+		interval = time.Duration(o.Interval) * time.Second
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExp), sdktrace.WithResource(res))
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp, sdkmetric.WithInterval(interval))),
+		sdkmetric.WithResource(res),
+	)
+	if err := registerGauges(mp.Meter(instrumentation)); err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	slog.Info("exporting OpenTelemetry traces and metrics")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx)); err != nil {
+			slog.Error("failed to flush OpenTelemetry data", "error", err)
+		}
+	}, nil
+}
+
+// registerGauges reports the results of each group's last pass, as the
+// Prometheus exporter does.
+func registerGauges(meter metric.Meter) error {
+	notAfter, err := meter.Int64ObservableGauge("crtwtch.cert.not_after", metric.WithUnit("s"),
+		metric.WithDescription("When the certificate that expires first in the chain expires, in Unix time."))
+	if err != nil {
+		return err
+	}
+	daysLeft, err := meter.Int64ObservableGauge("crtwtch.cert.days_left", metric.WithUnit("d"),
+		metric.WithDescription("Whole days until the certificate that expires first in the chain expires."))
+	if err != nil {
+		return err
+	}
+	success, err := meter.Int64ObservableGauge("crtwtch.check.success",
+		metric.WithDescription("Whether a certificate could be read from the site."))
+	if err != nil {
+		return err
+	}
+	duration, err := meter.Float64ObservableGauge("crtwtch.check.duration", metric.WithUnit("s"),
+		metric.WithDescription("How long checking the site took, retries included."))
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		metrics.each(func(group string, r *Result) {
+			attrs := metric.WithAttributes(attribute.String("crtwtch.group", group), attribute.String("crtwtch.site", r.Site))
+			if !r.Expiry.IsZero() {
+				o.ObserveInt64(notAfter, r.Expiry.Unix(), attrs)
+				o.ObserveInt64(daysLeft, int64(r.DaysLeft), attrs)
+			}
+			ok := int64(0)
+			if r.Err == nil && r.Status != StatusSkipped {
+				ok = 1
+			}
+			o.ObserveInt64(success, ok, attrs)
+			if r.Duration > 0 {
+				o.ObserveFloat64(duration, r.Duration.Seconds(), attrs)
+			}
+		})
+		return nil
+	}, notAfter, daysLeft, success, duration)
+	return err
+}
+
+// traceCheck checks site in a span of its own, which records the results.
+func (group *WatchGroup) traceCheck(ctx context.Context, site *Site, check func(ctx context.Context) []Result) []Result {
+	ctx, span := tracer.Start(ctx, "check "+site.Addr, trace.WithAttributes(
+		attribute.String("crtwtch.group", group.Name),
+		attribute.String("crtwtch.site", site.Addr),
+	))
+	defer span.End()
+	results := check(ctx)
+	for i := range results {
+		r := &results[i]
+		if len(results) == 1 {
+			span.SetAttributes(resultAttributes(r)...)
+		} else {
+			span.AddEvent("result", trace.WithAttributes(resultAttributes(r)...))
+		}
+		switch {
+		case r.Err != nil:
+			span.RecordError(r.Err)
+			span.SetStatus(codes.Error, r.Err.Error())
+		case r.Status.Level() >= slog.LevelError:
+			span.SetStatus(codes.Error, r.Status.String())
+		}
+	}
+	return results
+}
+
+// resultAttributes describes r, with the TLS attributes of the semantic
+// conventions for what the certificate and handshake are.
+func resultAttributes(r *Result) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("crtwtch.result", r.Site),
+		attribute.String("crtwtch.status", r.Status.String()),
+		attribute.Int("crtwtch.attempts", r.Attempts),
+	}
+	if r.Reason != "" {
+		attrs = append(attrs, attribute.String("crtwtch.reason", r.Reason))
+	}
+	if r.Expiry.IsZero() {
+		return attrs
+	}
+	attrs = append(attrs,
+		attribute.Int("crtwtch.days_left", r.DaysLeft),
+		attribute.String("tls.server.not_after", r.Expiry.UTC().Format(time.RFC3339)),
+		attribute.String("tls.server.issuer", r.IssuerDN),
+	)
+	if r.leafHash != "" {
+		attrs = append(attrs, attribute.String("tls.server.hash.sha256", strings.ToUpper(r.leafHash)))
+	}
+	if version, ok := strings.CutPrefix(r.TLSVersion, "TLS "); ok {
+		attrs = append(attrs, attribute.String("tls.protocol.name", "tls"), attribute.String("tls.protocol.version", version))
+	}
+	if r.CipherSuite != "" {
+		attrs = append(attrs, attribute.String("tls.cipher", r.CipherSuite))
+	}
+	return attrs
+}
+
+// traceNotify sends through ch in a span of its own. Notifications are not
+// tied to a pass, so each starts a trace.
+func (group *WatchGroup) traceNotify(ch channel, level slog.Level, send func() error) error {
+	_, span := tracer.Start(context.Background(), "notify "+ch.name, trace.WithAttributes(
+		attribute.String("crtwtch.group", group.Name),
+		attribute.String("crtwtch.notifier", ch.name),
+		attribute.String("crtwtch.level", level.String()),
+	))
+	defer span.End()
+	err := send()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
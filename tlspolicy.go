@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// allCipherSuites are offered in the handshake, including the insecure
+// ones Go leaves out by default, so servers that only speak those are
+// reported for it rather than failing the check.
+var allCipherSuites = func() []uint16 {
+	var ids []uint16
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}()
+
+// validCiphers checks that names are cipher suites as Go and IANA name
+// them, such as "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+func validCiphers(names []string) error {
+	for _, name := range names {
+		if !slices.ContainsFunc(allCipherSuites, func(id uint16) bool { return tls.CipherSuiteName(id) == name }) {
+			return fmt.Errorf("unknown cipher suite %q", name)
+		}
+	}
+	return nil
+}
+
+// ciphers returns the cipher suites the group allows, nil for any.
+func (group *WatchGroup) ciphers() []string {
+	if len(group.Ciphers) > 0 {
+		return group.Ciphers
+	}
+	return group.config.Ciphers
+}
+
+// checkTLSPolicy marks r when the handshake negotiated less than TLS 1.2,
+// which current clients refuse, or a cipher suite off the group's list.
+func (group *WatchGroup) checkTLSPolicy(r *Result, peer *peerCerts) {
+	if peer.version == 0 {
+		return // not from a handshake
+	}
+	var reason string
+	switch allowed := group.ciphers(); {
+	case peer.version < tls.VersionTLS12:
+		reason = "the server only negotiates " + r.TLSVersion
+	case allowed != nil && !slices.Contains(allowed, r.CipherSuite):
+		reason = "cipher suite " + r.CipherSuite + " is not allowed"
+	default:
+		return
+	}
+	r.Status, r.Reason = StatusTLSPolicy, reason
+	slog.Warn("handshake violates the TLS policy", "site", r.Site, "version", r.TLSVersion, "cipher", r.CipherSuite)
+}
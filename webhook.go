@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"text/template"
+)
+
+func init() {
+	RegisterNotifier("webhook", func(decode func(any) error) (Notifier, error) {
+		n := &WebhookNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.URL == "" {
+			return nil, fmt.Errorf("webhook: url is required")
+		}
+		if n.Method == "" {
+			n.Method = "POST"
+		}
+		if n.Body == "" {
+			n.Body = defaultWebhookBody
+		}
+		tpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(n.Body)
+		if err != nil {
+			return nil, fmt.Errorf("webhook: body: %w", err)
+		}
+		n.tpl = tpl
+		return n, nil
+	})
+}
+
+// WebhookNotifier sends a request rendered from a text/template to an
+// arbitrary endpoint. Any 2xx response counts as delivered.
+type WebhookNotifier struct {
+	URL     string            `toml:"url"`
+	Method  string            `toml:"method"`
+	Headers map[string]string `toml:"headers"`
+	Body    string            `toml:"body"`
+
+	tpl *template.Template
+}
+
+const defaultWebhookBody = `{"level": {{json .Level}}, "message": {{json .Message}}}`
+
+// webhookFuncs are available to body templates; json encodes any value so
+// strings can be embedded in JSON bodies safely.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhookData is the dot of the body template. Report is nil when the
+// notifier is handed a plain message.
+type webhookData struct {
+	Message string
+	Level   string
+	Report  *Report
+}
+
+func (n *WebhookNotifier) Send(msg string, level slog.Level) error {
+	return n.post(webhookData{Message: msg, Level: level.String()})
+}
+
+func (n *WebhookNotifier) SendReport(rep *Report) error {
+	return n.post(webhookData{Message: rep.Text(), Level: rep.Level().String(), Report: rep})
+}
+
+func (n *WebhookNotifier) post(data webhookData) error {
+	var body bytes.Buffer
+	if err := n.tpl.Execute(&body, data); err != nil {
+		return fmt.Errorf("webhook: render body: %w", err)
+	}
+	header := map[string]string{"Content-Type": "application/json"}
+	for k, v := range n.Headers {
+		header[k] = v
+	}
+	status, _, err := doHTTP(n.Method, n.URL, header, body.String())
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("webhook: unexpected status code: %d", status)
+	}
+	return nil
+}
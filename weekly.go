@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// weeklyTime is a day of the week and time of day such as "mon 09:00".
+type weeklyTime struct {
+	dailyTime
+	day time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func parseWeeklyTime(s string) (weeklyTime, error) {
+	if s == "" {
+		return weeklyTime{}, nil
+	}
+	day, clock, _ := strings.Cut(strings.TrimSpace(s), " ")
+	wd, ok := weekdays[strings.ToLower(day[:min(3, len(day))])]
+	if !ok {
+		return weeklyTime{}, fmt.Errorf("bad weekday in %q, want e.g. \"mon 09:00\"", s)
+	}
+	d, err := parseDailyTime(strings.TrimSpace(clock))
+	if err != nil {
+		return weeklyTime{}, err
+	}
+	return weeklyTime{dailyTime: d, day: wd}, nil
+}
+
+// last returns the latest weekly time at or before t.
+func (w weeklyTime) last(t time.Time) time.Time {
+	at := w.on(t).AddDate(0, 0, -int((t.Weekday()-w.day+7)%7))
+	if at.After(t) {
+		at = at.AddDate(0, 0, -7)
+	}
+	return at
+}
+
+func (w weeklyTime) next(t time.Time) time.Time { return w.last(t).AddDate(0, 0, 7) }
+
+// observe updates the per-site certificate history and, for the weekly
+// report, the checks and failures since the last one. It returns the sites
+// whose alerted certificate was replaced by one that is fine, with
+// PrevExpiry set, and those whose certificate now comes from another CA,
+// with PrevIssuer set. A new intermediate of the same CA organization is
+// not a change.
+func (group *WatchGroup) observe(rep *Report) (renewed, reissued []Result) {
+	state := group.config.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, r := range rep.Results {
+		if r.Status == StatusSkipped {
+			continue
+		}
+		key := stateKey(group.Name, r.Site)
+		st := state.Sites[key]
+		if st == nil {
+			st = &siteState{}
+			state.Sites[key] = st
+		}
+		if group.weeklyAt.enabled {
+			st.Checks++
+			if r.Err != nil {
+				st.Failures++
+			}
+		}
+		if r.Err != nil {
+			continue
+		}
+		if !st.Expiry.IsZero() && (r.Expiry.After(st.Expiry) || st.Serial != "" && r.Serial != st.Serial || st.replaced(r.leafHash)) {
+			st.PrevExpiry, st.Renewed = st.Expiry, rep.Date
+			if alerted := st.Status == StatusWarning.String() || st.Status == StatusCritical.String() || st.Status == StatusExpired.String(); alerted && r.Status == StatusOK {
+				r.PrevExpiry = st.Expiry
+				renewed = append(renewed, r)
+			}
+		}
+		st.Expiry, st.Serial = r.Expiry, r.Serial
+		if r.leafHash != "" {
+			st.Fingerprint = r.leafHash
+		}
+		st.Host, st.KeyPin, st.Seen = r.host, r.keyPin, rep.Date
+		st.SharedKey = group.site(r.addr()).SharedKey
+		if r.IssuerDN == "" {
+			continue
+		}
+		if st.IssuerOrg != "" && r.issuerOrg != st.IssuerOrg {
+			r.PrevIssuer = st.Issuer
+			reissued = append(reissued, r)
+		}
+		st.Issuer, st.IssuerOrg = r.IssuerDN, r.issuerOrg
+	}
+	state.save()
+	return renewed, reissued
+}
+
+// weekly sends a new weekly report when the group's weekly time has passed
+// since the last one. The first pass only starts the week.
+func (group *WatchGroup) weekly(rep *Report) error {
+	if !group.weeklyAt.enabled {
+		return nil
+	}
+	state := group.config.state
+	state.mu.Lock()
+	since, ok := state.Weeklies[group.Name]
+	if !ok {
+		state.Weeklies[group.Name] = rep.Date
+		state.save()
+	}
+	state.mu.Unlock()
+	if !ok || !since.Before(group.weeklyAt.last(rep.Date)) {
+		return nil
+	}
+	w := group.weekSummary(rep, since)
+	slog.Info("sending the weekly report", "group", group.Name, "renewed", len(w.Renewed), "expiring", len(w.Expiring), "flaky", len(w.Flaky))
+	if err := group.notifyText(w.text(), w.level()); err != nil {
+		return err
+	}
+	if group.WeeklyHTML != "" {
+		if err := w.writeHTML(group.WeeklyHTML); err != nil {
+			slog.Error("failed to write the weekly report", "dir", group.WeeklyHTML, "error", err)
+		}
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.Weeklies[group.Name] = rep.Date
+	for _, r := range rep.Results {
+		if st := state.Sites[stateKey(group.Name, r.Site)]; st != nil {
+			st.Checks, st.Failures = 0, 0
+		}
+	}
+	state.save()
+	return nil
+}
+
+// weekSummary is the content of a weekly report.
+type weekSummary struct {
+	Group    string
+	Since    time.Time
+	Date     time.Time
+	Labels   *labelSet
+	Renewed  []renewal
+	Expiring []Result // within the next 7 days or already expired
+	Flaky    []flakiness
+}
+
+type renewal struct {
+	Site     string
+	Old, New time.Time
+}
+
+type flakiness struct {
+	Site             string
+	Failures, Checks int
+}
+
+func (group *WatchGroup) weekSummary(rep *Report, since time.Time) *weekSummary {
+	w := &weekSummary{Group: group.Name, Since: since, Date: rep.Date, Labels: rep.labels()}
+	state := group.config.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, r := range rep.Results {
+		st := state.Sites[stateKey(group.Name, r.Site)]
+		if st == nil {
+			continue
+		}
+		if st.Renewed.After(since) {
+			w.Renewed = append(w.Renewed, renewal{Site: r.Site, Old: st.PrevExpiry, New: st.Expiry})
+		}
+		if r.Err == nil && r.DaysLeft <= 7 {
+			w.Expiring = append(w.Expiring, r)
+		}
+		// a site that always fails is down, not flaky
+		if st.Failures > 0 && st.Failures < st.Checks {
+			w.Flaky = append(w.Flaky, flakiness{Site: r.Site, Failures: st.Failures, Checks: st.Checks})
+		}
+	}
+	slices.SortStableFunc(w.Expiring, func(a, b Result) int { return a.DaysLeft - b.DaysLeft })
+	return w
+}
+
+func (w *weekSummary) level() slog.Level {
+	for _, r := range w.Expiring {
+		if r.Status == StatusExpired {
+			return slog.LevelError
+		}
+	}
+	if len(w.Expiring) > 0 || len(w.Flaky) > 0 {
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+func (w *weekSummary) text() string {
+	l := w.Labels
+	lines := []string{fmt.Sprintf(l.WeeklyTitle, w.Date.Format("2006-01-02"), w.Group)}
+	section := func(title string, items []string) {
+		lines = append(lines, "", title+":")
+		if len(items) == 0 {
+			items = []string{l.None}
+		}
+		for _, item := range items {
+			lines = append(lines, "  "+item)
+		}
+	}
+	var items []string
+	for _, rn := range w.Renewed {
+		items = append(items, fmt.Sprintf("%s: %s → %s", rn.Site, rn.Old.Format("2006-01-02"), rn.New.Format("2006-01-02")))
+	}
+	section(l.Renewed, items)
+	items = nil
+	for _, r := range w.Expiring {
+		items = append(items, r.Message())
+	}
+	section(l.ExpiringSoon, items)
+	items = nil
+	for _, f := range w.Flaky {
+		items = append(items, fmt.Sprintf("%s: %d/%d", f.Site, f.Failures, f.Checks))
+	}
+	section(l.Flaky, items)
+	return strings.Join(lines, "\n")
+}
+
+var weeklyTemplate = template.Must(template.New("weekly").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head><body>
+<h2>{{.Title}}</h2>
+{{with .Summary}}{{$l := .Labels}}
+<h3>{{$l.Renewed}}</h3>
+{{if .Renewed}}<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+{{range .Renewed}}<tr><td>{{.Site}}</td><td>{{.Old.Format "2006-01-02"}}</td><td>{{.New.Format "2006-01-02"}}</td></tr>
+{{end}}</table>{{else}}<p>{{$l.None}}</p>{{end}}
+<h3>{{$l.ExpiringSoon}}</h3>
+{{if .Expiring}}<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+<tr><th>{{$l.Site}}</th><th>{{$l.Expiry}}</th><th>{{$l.DaysLeft}}</th></tr>
+{{range .Expiring}}<tr><td>{{.Site}}</td><td>{{.Expiry.Format "2006-01-02"}}</td><td>{{.DaysLeft}}</td></tr>
+{{end}}</table>{{else}}<p>{{$l.None}}</p>{{end}}
+<h3>{{$l.Flaky}}</h3>
+{{if .Flaky}}<table border="1" cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+{{range .Flaky}}<tr><td>{{.Site}}</td><td>{{.Failures}}/{{.Checks}}</td></tr>
+{{end}}</table>{{else}}<p>{{$l.None}}</p>{{end}}
+{{end}}
+</body></html>
+`))
+
+// writeHTML writes the report to dir as <group>-<date>.html.
+func (w *weekSummary) writeHTML(dir string) error {
+	var buf bytes.Buffer
+	data := struct {
+		Title   string
+		Summary *weekSummary
+	}{Title: fmt.Sprintf(w.Labels.WeeklyTitle, w.Date.Format("2006-01-02"), w.Group), Summary: w}
+	if err := weeklyTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.html", strings.ReplaceAll(w.Group, string(filepath.Separator), "_"), w.Date.Format("2006-01-02"))
+	return os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644)
+}
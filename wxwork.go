@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+func init() {
+	RegisterNotifier("wxwork", func(decode func(any) error) (Notifier, error) {
+		n := &WxworkNotifier{}
+		if err := decode(n); err != nil {
+			return nil, err
+		}
+		if n.Token == "" {
+			return nil, fmt.Errorf("wxwork: token is required")
+		}
+		return n, n.validate()
+	})
+}
+
+// WxworkNotifier posts to a WeChat Work (WeCom) group robot webhook.
+// MsgType is "text" (default) or "markdown". Alerts about expired
+// certificates @-mention MentionedList (userids, or "@all") and
+// MentionedMobileList; markdown messages can only mention userids.
+type WxworkNotifier struct {
+	Token               string   `toml:"token"`
+	MsgType             string   `toml:"msgtype"`
+	MentionedList       []string `toml:"mentioned_list"`
+	MentionedMobileList []string `toml:"mentioned_mobile_list"`
+}
+
+func (n *WxworkNotifier) validate() error {
+	switch n.MsgType {
+	case "", "text", "markdown":
+		return nil
+	}
+	return fmt.Errorf("wxwork: unknown msgtype %q", n.MsgType)
+}
+
+// WeCom rejects text content over 2048 bytes and markdown over 4096 bytes.
+const (
+	wxworkTextLimit     = 2048
+	wxworkMarkdownLimit = 4096
+)
+
+type wxworkText struct {
+	MsgType string `json:"msgtype"`
+	Text    struct {
+		Content             string   `json:"content"`
+		MentionedList       []string `json:"mentioned_list,omitempty"`
+		MentionedMobileList []string `json:"mentioned_mobile_list,omitempty"`
+	} `json:"text"`
+}
+
+type wxworkMarkdown struct {
+	MsgType  string `json:"msgtype"`
+	Markdown struct {
+		Content string `json:"content"`
+	} `json:"markdown"`
+}
+
+type wxworkResponse struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (n *WxworkNotifier) Send(msg string, level slog.Level) error {
+	return n.sendText(msg, level >= slog.LevelError)
+}
+
+func (n *WxworkNotifier) SendReport(rep *Report) error {
+	mention := false
+	for _, r := range rep.Results {
+		if r.Status == StatusExpired {
+			mention = true
+			break
+		}
+	}
+	if n.MsgType != "markdown" {
+		return n.sendText(rep.Text(), mention)
+	}
+	msg := wxworkMarkdown{MsgType: "markdown"}
+	msg.Markdown.Content = wxworkMarkdownReport(rep)
+	if mention {
+		for _, id := range n.MentionedList {
+			if id != "@all" {
+				msg.Markdown.Content += fmt.Sprintf("\n<@%s>", id)
+			}
+		}
+	}
+	for _, part := range chunkMessage(msg.Markdown.Content, wxworkMarkdownLimit) {
+		msg.Markdown.Content = part
+		if err := n.post(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendText sends content as one or more text messages; only the first part
+// carries the mentions.
+func (n *WxworkNotifier) sendText(content string, mention bool) error {
+	for i, part := range chunkMessage(content, wxworkTextLimit) {
+		msg := wxworkText{MsgType: "text"}
+		msg.Text.Content = part
+		if mention && i == 0 {
+			msg.Text.MentionedList = n.MentionedList
+			msg.Text.MentionedMobileList = n.MentionedMobileList
+		}
+		if err := n.post(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wxworkMarkdownReport renders the report with WeCom's font colors: info
+// is green, warning is orange and comment is grey.
+func wxworkMarkdownReport(rep *Report) string {
+	color := "info"
+	if rep.Level() > slog.LevelInfo {
+		color = "warning"
+	}
+	lines := []string{fmt.Sprintf(`**<font color="%s">%s</font>**`, color, rep.Title())}
+	for _, r := range rep.Shown() {
+		lines = append(lines, "> "+wxworkMarkdownLine(&r))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func wxworkMarkdownLine(r *Result) string {
+	l := r.labels()
+	status := l.Statuses[r.Status]
+	expire := r.Expiry.Format("2006-01-02")
+	switch r.Status {
+	case StatusFailed, StatusSkipped:
+		return fmt.Sprintf(`<font color="comment">%s</font> %s`, status, r.Site)
+	case StatusExpired:
+		return fmt.Sprintf(`<font color="warning">%s</font> %s (%s: %s)`, status, r.Site, l.Expiry, expire)
+	case StatusUntrusted, StatusMismatch, StatusRevoked, StatusOCSPFailed, StatusStaple, StatusCT, StatusUnexpectedIssuer, StatusPinMismatch, StatusHygiene, StatusTLSPolicy, StatusSelfSigned, StatusValidity, StatusDANE, StatusRenewalDue, StatusDrift, StatusDuplicate, StatusSlow:
+		return fmt.Sprintf(`<font color="warning">%s</font> %s (%s)`, status, r.Site, r.Reason)
+	}
+	leftLabel, left := r.timeLeft()
+	if r.Status == StatusWarning || r.Status == StatusCritical {
+		return fmt.Sprintf(`<font color="warning">%s</font> %s (%s: <font color="warning">%d</font>, %s: %s)`, status, r.Site, leftLabel, left, l.Expiry, expire)
+	}
+	return fmt.Sprintf(`%s (%s: <font color="info">%d</font>, %s: %s)`, r.Site, leftLabel, left, l.Expiry, expire)
+}
+
+// wxworkURL is the webhook endpoint the token is appended to.
+var wxworkURL = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key="
+
+func (n *WxworkNotifier) post(msg any) error {
+	throttle("wxwork")
+	body, err := postJSON(wxworkURL+n.Token, msg)
+	if err != nil {
+		return fmt.Errorf("wxwork: %w", err)
+	}
+	slog.Debug("wxwork response", "body", string(body))
+	var resp wxworkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("wxwork: bad response: %w", err)
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("wxwork: errcode %d: %s", resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
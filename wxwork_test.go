@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trickySites are site names that break JSON built by formatting strings.
+var trickySites = []string{
+	`quote"d.example.com`,
+	`back\slash.example.com`,
+	"new\nline.example.com",
+	`mixed"\` + "\n\t" + `"end`,
+}
+
+// captureWxwork points the notifier at a local server and returns the
+// bodies it receives, each checked to be one JSON object.
+func captureWxwork(t *testing.T) *[]map[string]any {
+	t.Helper()
+	var got []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("body is not a JSON object: %v\n%s", err, body)
+		}
+		got = append(got, payload)
+		io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(srv.Close)
+	old := wxworkURL
+	wxworkURL = srv.URL + "/?key="
+	t.Cleanup(func() { wxworkURL = old })
+	return &got
+}
+
+func trickyReport() *Report {
+	rep := &Report{Group: `g"r\p`, Date: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	for _, site := range trickySites {
+		rep.Results = append(rep.Results, Result{
+			Site:     site,
+			Expiry:   rep.Date.Add(5 * 24 * time.Hour),
+			Left:     5 * 24 * time.Hour,
+			DaysLeft: 5,
+			Status:   StatusWarning,
+		})
+	}
+	return rep
+}
+
+// content is the message text of a decoded wxwork payload.
+func content(t *testing.T, payload map[string]any) string {
+	t.Helper()
+	msgType, _ := payload["msgtype"].(string)
+	body, _ := payload[msgType].(map[string]any)
+	c, ok := body["content"].(string)
+	if !ok {
+		t.Fatalf("payload without %q content: %v", msgType, payload)
+	}
+	return c
+}
+
+func TestWxworkSendEscaping(t *testing.T) {
+	got := captureWxwork(t)
+	n := &WxworkNotifier{Token: "t"}
+	msg := strings.Join(trickySites, " ")
+	if err := n.Send(msg, slog.LevelError); err != nil {
+		t.Fatal(err)
+	}
+	if len(*got) != 1 {
+		t.Fatalf("got %d messages, want 1", len(*got))
+	}
+	if c := content(t, (*got)[0]); c != msg {
+		t.Errorf("content = %q, want %q", c, msg)
+	}
+}
+
+func TestWxworkReportEscaping(t *testing.T) {
+	for _, msgType := range []string{"text", "markdown"} {
+		t.Run(msgType, func(t *testing.T) {
+			got := captureWxwork(t)
+			n := &WxworkNotifier{Token: "t", MsgType: msgType}
+			if err := n.SendReport(trickyReport()); err != nil {
+				t.Fatal(err)
+			}
+			if len(*got) != 1 {
+				t.Fatalf("got %d messages, want 1", len(*got))
+			}
+			if mt := (*got)[0]["msgtype"]; mt != msgType {
+				t.Errorf("msgtype = %v, want %s", mt, msgType)
+			}
+			c := content(t, (*got)[0])
+			for _, site := range trickySites {
+				if !strings.Contains(c, site) {
+					t.Errorf("content lost site %q:\n%s", site, c)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	for _, s := range append(trickySites, "<b>&amp;</b>") {
+		msg := wxworkText{MsgType: "text"}
+		msg.Text.Content = s
+		payload, err := marshalJSON(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back wxworkText
+		if err := json.Unmarshal([]byte(payload), &back); err != nil {
+			t.Fatalf("marshalJSON(%q) = %s: %v", s, payload, err)
+		}
+		if back.Text.Content != s {
+			t.Errorf("round trip of %q gave %q", s, back.Text.Content)
+		}
+	}
+}
+
+func TestWxworkMarkdownLineHours(t *testing.T) {
+	expiry := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		left time.Duration
+		want string
+	}{
+		{6 * time.Hour, "剩余小时: <font color=\"warning\">6</font>"},
+		{47 * time.Hour, "剩余小时: <font color=\"warning\">47</font>"},
+		{48 * time.Hour, "剩余天数: <font color=\"warning\">2</font>"},
+	} {
+		r := Result{Site: "example.com", Expiry: expiry, Left: tt.left, DaysLeft: daysOf(tt.left), Status: StatusCritical}
+		if got := wxworkMarkdownLine(&r); !strings.Contains(got, tt.want) {
+			t.Errorf("wxworkMarkdownLine with %v left = %q, want it to contain %q", tt.left, got, tt.want)
+		}
+	}
+}